@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/pkg/postgres"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultMigrationsDir = "internal/pkg/postgres/migrations"
+
+func migrateCmd() *cobra.Command {
+	var dryRun bool
+	var command = &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+		},
+	}
+
+	command.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the SQL instead of running it")
+
+	newMigrator := func() (*postgres.Migrator, error) {
+		cfg := config.Load()
+		migrator, err := postgres.NewMigrator(postgres.NewGORM(&cfg.Database))
+		if err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			migrator.DryRun(os.Stdout)
+		}
+
+		return migrator, nil
+	}
+
+	command.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				migrator, err := newMigrator()
+				if err != nil {
+					return err
+				}
+
+				return migrator.Up(cmd.Context())
+			},
+		},
+		&cobra.Command{
+			Use:   "down N",
+			Short: "Revert the last N applied migrations",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					return errors.New("N must be a positive number")
+				}
+
+				migrator, err := newMigrator()
+				if err != nil {
+					return err
+				}
+
+				return migrator.Down(cmd.Context(), n)
+			},
+		},
+		&cobra.Command{
+			Use:   "goto VERSION",
+			Short: "Migrate up or down to VERSION, 0 reverts everything",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				version, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil || version < 0 {
+					return errors.New("VERSION must be a migration version or 0")
+				}
+
+				migrator, err := newMigrator()
+				if err != nil {
+					return err
+				}
+
+				return migrator.Goto(cmd.Context(), version)
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "List migrations and whether they are applied",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				migrator, err := newMigrator()
+				if err != nil {
+					return err
+				}
+
+				statuses, err := migrator.Status(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+				for _, status := range statuses {
+					appliedAt := "pending"
+					if status.AppliedAt != nil {
+						appliedAt = status.AppliedAt.Format(time.RFC3339)
+						if status.Missing {
+							appliedAt += " (file missing)"
+						}
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+				}
+
+				return w.Flush()
+			},
+		},
+		createMigrationCmd(&dryRun),
+	)
+
+	return command
+}
+
+func createMigrationCmd(dryRun *bool) *cobra.Command {
+	var dir string
+	var command = &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create empty up and down files for a new migration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			up, down, err := postgres.NewMigrationFiles(dir, args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, file := range []string{up, down} {
+				if !*dryRun {
+					if err := os.WriteFile(file, nil, 0o644); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), file)
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&dir, "dir", defaultMigrationsDir, "Directory holding the migration files")
+	return command
+}
@@ -15,6 +15,7 @@ func Run() {
 	}
 
 	command.AddCommand(apiCmd())
+	command.AddCommand(migrateCmd())
 
 	if err := command.Execute(); err != nil {
 		log.Fatal().Msgf("failed to execute command, err: %v", err.Error())
@@ -5,6 +5,7 @@ go 1.23.1
 require github.com/caarlos0/env/v11 v11.3.1
 
 require (
+	github.com/jackc/pgx/v5 v5.5.5
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/crypto v0.17.0
 	gorm.io/gorm v1.25.12
@@ -18,7 +19,6 @@ require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/jackc/pgx/v5 v5.5.5 // indirect
 	github.com/jackc/puddle/v2 v2.2.1 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -39,6 +39,6 @@ require (
 	github.com/redis/go-redis/v9 v9.12.1
 	github.com/rs/zerolog v1.33.0
 	github.com/spf13/cobra v1.9.1
-	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/text v0.14.0
 	gorm.io/driver/postgres v1.5.11
 )
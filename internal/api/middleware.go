@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net-http-boilerplate/internal/api/resp"
 	"net/http"
 	"runtime/debug"
 	"strings"
@@ -134,32 +135,76 @@ func loggerHandler(filter func(w http.ResponseWriter, r *http.Request) bool) fun
 	}
 }
 
+// sensitiveFields are request body fields that are never logged.
+var sensitiveFields = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"mfa_token":        true,
+	"code":             true,
+	"recovery_code":    true,
+	"secret":           true,
+}
+
+const redacted = "[REDACTED]"
+
 func formatReqBody(r *http.Request, data []byte) string {
 	var js map[string]interface{}
 	if json.Unmarshal(data, &js) != nil {
 		return string(data)
 	}
 
-	result := new(bytes.Buffer)
-	if err := json.Compact(result, data); err != nil {
-		log.Ctx(r.Context()).Error().Msgf("error compacting body request json: %s", err.Error())
+	result, err := json.Marshal(redact(js))
+	if err != nil {
+		log.Ctx(r.Context()).Error().Msgf("error encoding body request json: %s", err.Error())
 		return ""
 	}
 
-	return result.String()
+	return string(result)
 }
 
-func realIPHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if rip := realIP(r); rip != "" {
-			r.RemoteAddr = rip
+// redact replaces the values of sensitive fields, at any depth, so secrets
+// such as passwords, tokens and MFA codes never end up in the logs.
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redact(value)
 		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	return v
+}
+
+// realIPHandler replaces RemoteAddr with the client IP reported by one of the
+// trusted proxies. Anyone else keeps their socket address; otherwise clients
+// could pick their own IP, e.g. to get around the login lockout.
+func realIPHandler(trusted []*net.IPNet) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isTrustedProxy(hostIP(r.RemoteAddr), trusted) {
+				if rip := realIP(r, trusted); rip != "" {
+					r.RemoteAddr = rip
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-func realIP(r *http.Request) string {
+func realIP(r *http.Request, trusted []*net.IPNet) string {
 	trueClientIP := http.CanonicalHeaderKey("True-Client-IP")
 	xForwardedFor := http.CanonicalHeaderKey("X-Forwarded-For")
 	xRealIP := http.CanonicalHeaderKey("X-Real-IP")
@@ -170,11 +215,16 @@ func realIP(r *http.Request) string {
 	} else if xrip := r.Header.Get(xRealIP); xrip != "" {
 		ip = xrip
 	} else if xff := r.Header.Get(xForwardedFor); xff != "" {
-		i := strings.Index(xff, ",")
-		if i == -1 {
-			i = len(xff)
+		// Each proxy appends the address it got the request from, so the
+		// client is the right-most entry that is not one of our proxies.
+		// Anything left of it was sent by the client and may be made up.
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(hops[i])
+			if !isTrustedProxy(ip, trusted) {
+				break
+			}
 		}
-		ip = xff[:i]
 	}
 	if ip == "" || net.ParseIP(ip) == nil {
 		return ""
@@ -182,6 +232,61 @@ func realIP(r *http.Request) string {
 	return ip
 }
 
+// parseTrustedProxies parses a list of IPs and CIDRs.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var trusted []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		trusted = append(trusted, network)
+	}
+
+	return trusted, nil
+}
+
+func isTrustedProxy(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostIP strips the port from a RemoteAddr.
+func hostIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
 func recoverHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -203,7 +308,7 @@ func recoverHandler(next http.Handler) http.Handler {
 					Bytes("stack", debug.Stack()).
 					Msg("panic recover")
 
-				w.WriteHeader(http.StatusInternalServerError)
+				resp.WriteError(w, err)
 			}
 		}()
 
@@ -213,13 +318,16 @@ func recoverHandler(next http.Handler) http.Handler {
 
 func requestIDHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestIDHeader := "X-Request-Id"
-		if r.Header.Get(requestIDHeader) == "" {
-			r.Header.Set(requestIDHeader, uuid.NewString())
+		if r.Header.Get(resp.RequestIDHeader) == "" {
+			r.Header.Set(resp.RequestIDHeader, uuid.NewString())
 		}
 
+		// Echo the ID so clients can quote it; error responses report it as
+		// the problem instance.
+		w.Header().Set(resp.RequestIDHeader, r.Header.Get(resp.RequestIDHeader))
+
 		ctx := log.With().
-			Str("request_id", r.Header.Get(requestIDHeader)).
+			Str("request_id", r.Header.Get(resp.RequestIDHeader)).
 			Logger().
 			WithContext(r.Context())
 
@@ -234,7 +342,7 @@ func corsHandler(next http.Handler) http.Handler {
 
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, HEAD, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, POST, HEAD, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 			w.WriteHeader(http.StatusNoContent)
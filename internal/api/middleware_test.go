@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"net-http-boilerplate/internal/api/resp"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatReqBodyRedacts(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "top level",
+			body: `{"email":"a@example.com","password":"hunter2"}`,
+			want: `{"email":"a@example.com","password":"[REDACTED]"}`,
+		},
+		{
+			name: "any case",
+			body: `{"Refresh_Token":"rt","MFA_TOKEN":"mt"}`,
+			want: `{"MFA_TOKEN":"[REDACTED]","Refresh_Token":"[REDACTED]"}`,
+		},
+		{
+			name: "nested object",
+			body: `{"user":{"name":"a","credentials":{"current_password":"old","new_password":"new"}}}`,
+			want: `{"user":{"credentials":{"current_password":"[REDACTED]","new_password":"[REDACTED]"},"name":"a"}}`,
+		},
+		{
+			name: "objects in arrays",
+			body: `{"factors":[{"code":"123456"},{"recovery_code":"abcd"},"plain"]}`,
+			want: `{"factors":[{"code":"[REDACTED]"},{"recovery_code":"[REDACTED]"},"plain"]}`,
+		},
+		{
+			name: "sensitive object replaced whole",
+			body: `{"secret":{"value":"s"}}`,
+			want: `{"secret":"[REDACTED]"}`,
+		},
+		{
+			name: "not json",
+			body: `name=a`,
+			want: `name=a`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			if got := formatReqBody(r, []byte(tt.body)); got != tt.want {
+				t.Errorf("formatReqBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRealIPHandler(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer without headers",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "untrusted peer spoofing X-Forwarded-For",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "untrusted peer spoofing True-Client-IP",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"True-Client-IP": "198.51.100.1"},
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "untrusted peer spoofing X-Real-IP",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "trusted proxy with True-Client-IP",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"True-Client-IP": "198.51.100.1", "X-Forwarded-For": "198.51.100.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy with X-Real-IP",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "single hop",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "right-most untrusted hop wins over a spoofed left one",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.5"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "every hop trusted",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.9, 10.0.0.5"},
+			want:       "10.0.0.9",
+		},
+		{
+			name:       "garbage from a trusted proxy",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-For": "not-an-ip"},
+			want:       "10.1.2.3:1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := realIPHandler(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", proxies: nil, want: nil},
+		{name: "blank entries", proxies: []string{"", "  "}, want: nil},
+		{name: "ipv4", proxies: []string{" 192.0.2.1 "}, want: []string{"192.0.2.1/32"}},
+		{name: "ipv6", proxies: []string{"2001:db8::1"}, want: []string{"2001:db8::1/128"}},
+		{name: "cidrs", proxies: []string{"10.0.0.0/8", "2001:db8::/32"}, want: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{name: "cidr normalised", proxies: []string{"10.1.2.3/8"}, want: []string{"10.0.0.0/8"}},
+		{name: "hostname", proxies: []string{"proxy.internal"}, wantErr: true},
+		{name: "bad cidr", proxies: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := parseTrustedProxies(tt.proxies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrustedProxies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, network := range trusted {
+				got = append(got, network.String())
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseTrustedProxies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDIsProblemInstance(t *testing.T) {
+	handler := requestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp.WriteError(w, apperror.ErrResourceNotFound)
+	}))
+
+	tests := []struct {
+		name string
+		sent string
+	}{
+		{"sent by the client", "client-request-id"},
+		{"generated", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.sent != "" {
+				r.Header.Set(resp.RequestIDHeader, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			var problem resp.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+
+			id := rec.Header().Get(resp.RequestIDHeader)
+			if id == "" || (tt.sent != "" && id != tt.sent) {
+				t.Errorf("%s = %q, want %q or a generated one", resp.RequestIDHeader, id, tt.sent)
+			}
+
+			if problem.Instance != id {
+				t.Errorf("instance = %q, want the request id %q", problem.Instance, id)
+			}
+		})
+	}
+}
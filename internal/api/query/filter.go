@@ -0,0 +1,213 @@
+package query
+
+import (
+	"fmt"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/cursor"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPerPage = 10
+	maxPerPage     = 100
+)
+
+// Options describes what a list endpoint accepts.
+type Options struct {
+	// Sortable maps the accepted sort_by values to the columns they order
+	// by. Nothing else ever reaches ORDER BY.
+	Sortable map[string]string
+	// Cursors decodes the cursor parameter.
+	Cursors *cursor.Codec
+}
+
+// ParseFilter reads the common list parameters of r into a Filter:
+//
+//	page, perPage          offset pagination, perPage at most 100
+//	cursor, limit          cursor pagination instead, limit at most 100
+//	q                      search text
+//	sort_by, sort_order    one of Options.Sortable, asc or desc
+//	start_date, end_date   RFC 3339 bounds on created_at
+//	category_id, author_id exact matches
+//	status                 draft, published or archived
+//	include_deleted        admins only, see auth.IncludeDeleted
+//
+// Repositories apply the filters that make sense for them. A malformed value
+// is a validation error.
+func ParseFilter(r *http.Request, opts Options) (*entity.Filter, error) {
+	q := r.URL.Query()
+	filter := &entity.Filter{}
+
+	if search := strings.TrimSpace(q.Get("q")); search != "" {
+		filter.Query = &search
+	}
+
+	if sortBy := q.Get("sort_by"); sortBy != "" {
+		column, ok := opts.Sortable[sortBy]
+		if !ok {
+			return nil, apperror.Validation(fmt.Sprintf("'sort_by' must be one of: %s", strings.Join(sortableNames(opts), ", ")))
+		}
+		filter.SortBy = &column
+	}
+
+	if sortOrder := q.Get("sort_order"); sortOrder != "" {
+		order := strings.ToUpper(sortOrder)
+		if order != "ASC" && order != "DESC" {
+			return nil, apperror.Validation("'sort_order' must be asc or desc")
+		}
+		filter.SortOrder = &order
+	}
+
+	var err error
+	if filter.StartDate, err = timeParam(q, "start_date"); err != nil {
+		return nil, err
+	}
+
+	if filter.EndDate, err = timeParam(q, "end_date"); err != nil {
+		return nil, err
+	}
+
+	if filter.StartDate != nil && filter.EndDate != nil && filter.EndDate.Before(*filter.StartDate) {
+		return nil, apperror.Validation("'end_date' must not be before 'start_date'")
+	}
+
+	if raw := q.Get("category_id"); raw != "" {
+		categoryID, err := strconv.Atoi(raw)
+		if err != nil || categoryID < 1 {
+			return nil, apperror.Validation("'category_id' must be a positive number")
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	if raw := q.Get("author_id"); raw != "" {
+		authorID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, apperror.Validation("'author_id' must be a UUID")
+		}
+		filter.AuthorID = &authorID
+	}
+
+	if status := q.Get("status"); status != "" {
+		if status != entity.PostStatusDraft && status != entity.PostStatusPublished && status != entity.PostStatusArchived {
+			return nil, apperror.Validation("'status' must be draft, published or archived")
+		}
+		filter.Status = &status
+	}
+
+	if q.Has("cursor") || q.Has("limit") {
+		err = parseCursor(q, opts, filter)
+	} else {
+		err = parsePage(q, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.IncludeDeleted, err = auth.IncludeDeleted(r); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func parsePage(q url.Values, filter *entity.Filter) error {
+	page, err := intParam(q.Get("page"), 1)
+	if err != nil || page < 1 {
+		return apperror.Validation("'page' must be a positive number")
+	}
+
+	perPage, err := intParam(q.Get("perPage"), defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		return apperror.Validation(fmt.Sprintf("'perPage' must be a number between 1 and %d", maxPerPage))
+	}
+
+	filter.Page = &page
+	filter.PerPage = &perPage
+	return nil
+}
+
+// parseCursor switches the filter to cursor pagination. A cursor carries the
+// order it was created with, which wins over sort_by and sort_order.
+func parseCursor(q url.Values, opts Options, filter *entity.Filter) error {
+	if q.Has("page") || q.Has("perPage") {
+		return apperror.Validation("'page' and 'perPage' cannot be combined with 'cursor' or 'limit'")
+	}
+
+	limit, err := intParam(q.Get("limit"), defaultPerPage)
+	if err != nil || limit < 1 || limit > maxPerPage {
+		return apperror.Validation(fmt.Sprintf("'limit' must be a number between 1 and %d", maxPerPage))
+	}
+	filter.Limit = &limit
+
+	raw := q.Get("cursor")
+	if raw == "" {
+		// Relevance is not a stable key to page through.
+		if filter.Query != nil && filter.SortBy == nil {
+			return apperror.Validation("'sort_by' is required to page search results with a cursor")
+		}
+		return nil
+	}
+
+	cur, err := opts.Cursors.Decode(raw)
+	if err != nil || !slices.Contains(sortableColumns(opts), cur.SortBy) {
+		return apperror.Validation("'cursor' is invalid")
+	}
+
+	order := "ASC"
+	if cur.Desc {
+		order = "DESC"
+	}
+
+	filter.Cursor = cur
+	filter.SortBy = &cur.SortBy
+	filter.SortOrder = &order
+	return nil
+}
+
+func intParam(raw string, fallback int) (int, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(raw)
+}
+
+func timeParam(q url.Values, name string) (*time.Time, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, apperror.Validation(fmt.Sprintf("'%s' must be an RFC 3339 timestamp, e.g. 2024-01-02T15:04:05Z", name))
+	}
+
+	return &t, nil
+}
+
+func sortableColumns(opts Options) []string {
+	columns := make([]string, 0, len(opts.Sortable))
+	for _, column := range opts.Sortable {
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func sortableNames(opts Options) []string {
+	names := make([]string, 0, len(opts.Sortable))
+	for name := range opts.Sortable {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"net-http-boilerplate/internal/api/resp"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/cursor"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseFilterStatus(t *testing.T) {
+	cursors := cursor.New("test-secret")
+	opts := Options{
+		Sortable: map[string]string{"title": "title", "created_at": "created_at"},
+		Cursors:  cursors,
+	}
+	valid := cursors.Encode(&entity.Cursor{SortBy: "created_at", Value: "2024-01-01T00:00:00Z", ID: "1"})
+	unsortable := cursors.Encode(&entity.Cursor{SortBy: "password", Value: "x", ID: "1"})
+
+	user := &auth.Principal{ID: uuid.New(), Roles: []string{entity.RoleUser}}
+	admin := &auth.Principal{ID: uuid.New(), Roles: []string{entity.RoleAdmin}}
+
+	tests := []struct {
+		name      string
+		query     string
+		principal *auth.Principal
+		status    int
+	}{
+		{"no parameters", "", nil, http.StatusOK},
+		{"every filter", "q=go&sort_by=title&sort_order=desc&start_date=2024-01-01T00:00:00Z&end_date=2024-02-01T00:00:00Z&page=2&perPage=100", nil, http.StatusOK},
+		{"unknown sort_by", "sort_by=password", nil, http.StatusBadRequest},
+		{"sort_by as a column expression", "sort_by=title%3BDROP%20TABLE%20posts", nil, http.StatusBadRequest},
+		{"unknown sort_order", "sort_order=sideways", nil, http.StatusBadRequest},
+		{"malformed start_date", "start_date=2024-01-01", nil, http.StatusBadRequest},
+		{"malformed end_date", "end_date=yesterday", nil, http.StatusBadRequest},
+		{"end_date before start_date", "start_date=2024-02-01T00:00:00Z&end_date=2024-01-01T00:00:00Z", nil, http.StatusBadRequest},
+		{"perPage over 100", "perPage=101", nil, http.StatusBadRequest},
+		{"perPage zero", "perPage=0", nil, http.StatusBadRequest},
+		{"page zero", "page=0", nil, http.StatusBadRequest},
+		{"limit over 100", "limit=101", nil, http.StatusBadRequest},
+		{"cursor", "cursor=" + valid, nil, http.StatusOK},
+		{"cursor with page", "cursor=" + valid + "&page=2", nil, http.StatusBadRequest},
+		{"limit with perPage", "limit=10&perPage=10", nil, http.StatusBadRequest},
+		{"tampered cursor", "cursor=" + valid + "x", nil, http.StatusBadRequest},
+		{"cursor on an unsortable column", "cursor=" + unsortable, nil, http.StatusBadRequest},
+		{"search cursor without sort_by", "q=go&limit=10", nil, http.StatusBadRequest},
+		{"malformed category_id", "category_id=first", nil, http.StatusBadRequest},
+		{"malformed author_id", "author_id=42", nil, http.StatusBadRequest},
+		{"unknown status", "status=hidden", nil, http.StatusBadRequest},
+		{"malformed include_deleted", "include_deleted=maybe", admin, http.StatusBadRequest},
+		{"include_deleted as anonymous", "include_deleted=true", nil, http.StatusForbidden},
+		{"include_deleted as a user", "include_deleted=true", user, http.StatusForbidden},
+		{"include_deleted false as a user", "include_deleted=false", user, http.StatusOK},
+		{"include_deleted as an admin", "include_deleted=true", admin, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil)
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithUser(r.Context(), tt.principal))
+			}
+
+			_, err := ParseFilter(r, opts)
+
+			status := http.StatusOK
+			if err != nil {
+				rec := httptest.NewRecorder()
+				resp.WriteError(rec, err)
+				status = rec.Code
+			}
+
+			if status != tt.status {
+				t.Errorf("status = %d, want %d (error %v)", status, tt.status, err)
+			}
+		})
+	}
+}
+
+func TestParseFilterValues(t *testing.T) {
+	opts := Options{Sortable: map[string]string{"title": "posts.title"}}
+	r := httptest.NewRequest(http.MethodGet, "/posts?q=+go+&sort_by=title&sort_order=asc&start_date=2024-01-01T00:00:00Z&category_id=3&status=draft", nil)
+
+	filter, err := ParseFilter(r, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *filter.Query != "go" {
+		t.Errorf("query = %q, want %q", *filter.Query, "go")
+	}
+
+	if *filter.SortBy != "posts.title" || *filter.SortOrder != "ASC" {
+		t.Errorf("order = %s %s, want posts.title ASC", *filter.SortBy, *filter.SortOrder)
+	}
+
+	if filter.StartDate == nil || filter.StartDate.Year() != 2024 || filter.EndDate != nil {
+		t.Errorf("dates = %v, %v, want 2024-01-01 and none", filter.StartDate, filter.EndDate)
+	}
+
+	if *filter.CategoryID != 3 || *filter.Status != entity.PostStatusDraft {
+		t.Errorf("category_id = %d, status = %s, want 3 and draft", *filter.CategoryID, *filter.Status)
+	}
+
+	if *filter.Page != 1 || *filter.PerPage != defaultPerPage || filter.Limit != nil {
+		t.Errorf("page = %d, perPage = %d, want 1 and %d with no limit", *filter.Page, *filter.PerPage, defaultPerPage)
+	}
+}
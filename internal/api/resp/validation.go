@@ -0,0 +1,54 @@
+package resp
+
+import (
+	"fmt"
+	pkgvalidator "net-http-boilerplate/internal/pkg/validator"
+
+	"github.com/go-playground/validator"
+)
+
+// FieldError describes one failed validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func fieldErrors(errs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldMessage(fe),
+		})
+	}
+
+	return fields
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "numeric":
+		return "must contain only digits"
+	case "password":
+		return fmt.Sprintf("must be %d to %d characters with an upper case letter, a lower case letter and a digit",
+			pkgvalidator.PasswordMinLength, pkgvalidator.PasswordMaxLength)
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
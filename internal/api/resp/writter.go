@@ -5,7 +5,11 @@ import (
 	"errors"
 	"math"
 	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
 	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
 )
 
 // Meta contains pagination details.
@@ -22,12 +26,41 @@ type DataPaginate struct {
 	Meta    Meta   `json:"meta"`
 }
 
-// HTTPError is the JSON structure for errors.
-type HTTPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// CursorMeta holds the cursors of the neighbouring pages. A cursor is left
+// out at the respective end of the list.
+type CursorMeta struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// DataCursor wraps cursor-paginated data with its cursors.
+type DataCursor struct {
+	Message string     `json:"message"`
+	Data    any        `json:"data"`
+	Meta    CursorMeta `json:"meta"`
 }
 
+// Problem is an RFC 7807 problem details object, the body of every error
+// response.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+const (
+	// ProblemContentType is the media type of error responses.
+	ProblemContentType = "application/problem+json"
+	// RequestIDHeader carries the request ID that is reported as the
+	// problem instance.
+	RequestIDHeader = "X-Request-Id"
+
+	problemTypePrefix = "/problems/"
+)
+
 // SuccessResponse is a general response with optional data.
 type SuccessResponse struct {
 	Message string `json:"message"`
@@ -96,19 +129,77 @@ func WriteJSONWithPaginateResponse(w http.ResponseWriter, statusCode int, messag
 	WriteJSON(w, statusCode, response)
 }
 
-// WriteError sends an error response in a consistent JSON format.
+// WriteJSONWithCursorResponse sends a page of cursor-paginated data. A cursor
+// is left out at the respective end of the list.
+func WriteJSONWithCursorResponse(w http.ResponseWriter, statusCode int, message string, data any, next string, prev string) {
+	response := DataCursor{
+		Message: message,
+		Data:    data,
+		Meta: CursorMeta{
+			NextCursor: next,
+			PrevCursor: prev,
+		},
+	}
+
+	WriteJSON(w, statusCode, response)
+}
+
+// kindStatus is the one place domain error kinds are turned into statuses.
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindNotFound:     http.StatusNotFound,
+	apperror.KindConflict:     http.StatusConflict,
+	apperror.KindValidation:   http.StatusBadRequest,
+	apperror.KindUnauthorized: http.StatusUnauthorized,
+	apperror.KindForbidden:    http.StatusForbidden,
+	apperror.KindRateLimited:  http.StatusTooManyRequests,
+	apperror.KindUpstream:     http.StatusBadGateway,
+}
+
+// WriteError sends an error response as application/problem+json. Validation
+// errors become a 422 listing every field, apperror kinds and errors with an
+// HTTPStatusCode use their status and message as detail, and anything else is
+// a 500 that does not leak the underlying error.
 func WriteError(w http.ResponseWriter, err error) {
-	code := http.StatusInternalServerError
-	msg := "Something went wrong"
+	problem := Problem{
+		Status: http.StatusInternalServerError,
+		Detail: "Something went wrong",
+	}
 
+	var validationErrs validator.ValidationErrors
+	var appErr *apperror.Error
 	var httpErr interface{ HTTPStatusCode() int }
-	if errors.As(err, &httpErr) {
-		code = httpErr.HTTPStatusCode()
-		msg = err.Error()
+	switch {
+	case errors.As(err, &validationErrs):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Type = problemTypePrefix + "validation-failed"
+		problem.Detail = "validation failed"
+		problem.Errors = fieldErrors(validationErrs)
+	case errors.As(err, &appErr) && appErr.Kind != apperror.KindInternal:
+		problem.Status = kindStatus[appErr.Kind]
+		problem.Detail = appErr.Error()
+	case errors.As(err, &httpErr):
+		problem.Status = httpErr.HTTPStatusCode()
+		problem.Detail = err.Error()
 	}
 
-	WriteJSON(w, code, HTTPError{
-		Code:    code,
-		Message: msg,
-	})
+	if problem.Type == "" {
+		problem.Type = problemType(problem.Status)
+	}
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// problemType derives a stable, machine-readable type from the status, e.g.
+// "/problems/not-found" for 404.
+func problemType(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "about:blank"
+	}
+
+	return problemTypePrefix + strings.ToLower(strings.NewReplacer(" ", "-", "'", "").Replace(text))
 }
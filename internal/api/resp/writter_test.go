@@ -0,0 +1,174 @@
+package resp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	pkgvalidator "net-http-boilerplate/internal/pkg/validator"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// writeError runs WriteError on a recorder and decodes the problem it wrote.
+func writeError(t *testing.T, requestID string, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if requestID != "" {
+		rec.Header().Set(RequestIDHeader, requestID)
+	}
+	WriteError(rec, err)
+
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body %q is not a problem: %v", rec.Body.String(), err)
+	}
+
+	return rec, problem
+}
+
+func TestWriteErrorValidation(t *testing.T) {
+	type request struct {
+		Name     string `json:"name" validate:"required"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"password"`
+		Code     string `json:"code" validate:"len=6"`
+	}
+
+	err := pkgvalidator.NewValidator().ValidateStruct(request{Email: "not-an-email", Password: "weak", Code: "123"})
+	if err == nil {
+		t.Fatal("ValidateStruct() succeeded")
+	}
+
+	rec, problem := writeError(t, "", err)
+
+	if rec.Code != http.StatusUnprocessableEntity || problem.Status != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, body status = %d, want %d", rec.Code, problem.Status, http.StatusUnprocessableEntity)
+	}
+
+	if problem.Type != "/problems/validation-failed" || problem.Title != "Unprocessable Entity" {
+		t.Errorf("type = %q, title = %q", problem.Type, problem.Title)
+	}
+
+	want := []FieldError{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "password", Rule: "password", Message: "must be 8 to 72 characters with an upper case letter, a lower case letter and a digit"},
+		{Field: "code", Rule: "len", Param: "6", Message: "must be exactly 6 characters"},
+	}
+	if !reflect.DeepEqual(problem.Errors, want) {
+		t.Errorf("errors = %+v\nwant %+v", problem.Errors, want)
+	}
+}
+
+func TestWriteErrorProblem(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		requestID string
+		want      Problem
+	}{
+		{
+			name:      "status error",
+			err:       NewError(http.StatusTeapot, "short and stout"),
+			requestID: "req-1",
+			want: Problem{
+				Type:     "/problems/im-a-teapot",
+				Title:    "I'm a teapot",
+				Status:   http.StatusTeapot,
+				Detail:   "short and stout",
+				Instance: "req-1",
+			},
+		},
+		{
+			name: "internal error without a request id",
+			err:  errors.New("pq: password authentication failed for user app"),
+			want: Problem{
+				Type:   "/problems/internal-server-error",
+				Title:  "Internal Server Error",
+				Status: http.StatusInternalServerError,
+				Detail: "Something went wrong",
+			},
+		},
+		{
+			name:      "wrapped internal kind",
+			err:       apperror.Wrap(errors.New("dial tcp: connection refused"), apperror.KindInternal, "database down"),
+			requestID: "req-2",
+			want: Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal Server Error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "Something went wrong",
+				Instance: "req-2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, problem := writeError(t, tt.requestID, tt.err)
+
+			if rec.Code != tt.want.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want.Status)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+			}
+
+			if !reflect.DeepEqual(problem, tt.want) {
+				t.Errorf("problem = %+v\nwant %+v", problem, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorKindStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"not found", apperror.NotFound("post not found"), http.StatusNotFound, "post not found"},
+		{"conflict", apperror.ErrConflict, http.StatusConflict, "resource already exists"},
+		{"validation", apperror.Validation("'page' must be a positive number"), http.StatusBadRequest, "'page' must be a positive number"},
+		{"unauthorized", apperror.ErrInvalidToken, http.StatusUnauthorized, "invalid or expired token"},
+		{"forbidden", apperror.Forbidden("only admins may list deleted records"), http.StatusForbidden, "only admins may list deleted records"},
+		{"rate limited", apperror.ErrRateLimited, http.StatusTooManyRequests, "too many requests"},
+		{"upstream", apperror.ErrOAuthFailed, http.StatusBadGateway, "could not complete login with the provider"},
+		{"wrapped with another kind", apperror.Wrap(apperror.ErrInvalidToken, apperror.KindValidation, "invalid or expired reset token"), http.StatusBadRequest, "invalid or expired reset token"},
+		{"wrapped by fmt", fmt.Errorf("loading post: %w", apperror.ErrResourceNotFound), http.StatusNotFound, "resource not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, problem := writeError(t, "", tt.err)
+
+			if rec.Code != tt.status || problem.Status != tt.status {
+				t.Errorf("status = %d, body status = %d, want %d", rec.Code, problem.Status, tt.status)
+			}
+
+			if problem.Detail != tt.detail {
+				t.Errorf("detail = %q, want %q", problem.Detail, tt.detail)
+			}
+
+			if problem.Title != http.StatusText(tt.status) {
+				t.Errorf("title = %q, want %q", problem.Title, http.StatusText(tt.status))
+			}
+		})
+	}
+}
+
+// TestKindStatusComplete fails when a kind is added without a status, which
+// would otherwise answer 0.
+func TestKindStatusComplete(t *testing.T) {
+	for kind := apperror.KindNotFound; kind <= apperror.KindUpstream; kind++ {
+		if _, ok := kindStatus[kind]; !ok {
+			t.Errorf("kind %d has no status", kind)
+		}
+	}
+}
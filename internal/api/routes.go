@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net-http-boilerplate/internal/api/resp"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/jwt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UserRoutes are the user endpoints the router dispatches to.
+type UserRoutes interface {
+	Register(w http.ResponseWriter, r *http.Request)
+	Login(w http.ResponseWriter, r *http.Request)
+	LoginMFA(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerification(w http.ResponseWriter, r *http.Request)
+	ForgotPassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+	ConfirmEmailChange(w http.ResponseWriter, r *http.Request)
+	OAuthStart(w http.ResponseWriter, r *http.Request)
+	OAuthCallback(w http.ResponseWriter, r *http.Request)
+
+	Logout(w http.ResponseWriter, r *http.Request)
+	LogoutAll(w http.ResponseWriter, r *http.Request)
+	EnrollTOTP(w http.ResponseWriter, r *http.Request)
+	ConfirmTOTP(w http.ResponseWriter, r *http.Request)
+	DisableTOTP(w http.ResponseWriter, r *http.Request)
+	Me(w http.ResponseWriter, r *http.Request)
+	UpdateProfile(w http.ResponseWriter, r *http.Request)
+	DeleteAccount(w http.ResponseWriter, r *http.Request)
+	ChangeEmail(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+
+	ListUsers(w http.ResponseWriter, r *http.Request)
+	GetUser(w http.ResponseWriter, r *http.Request)
+	DeleteUser(w http.ResponseWriter, r *http.Request)
+	DisableUser(w http.ResponseWriter, r *http.Request)
+	EnableUser(w http.ResponseWriter, r *http.Request)
+	RestoreUser(w http.ResponseWriter, r *http.Request)
+	RevokeUserSessions(w http.ResponseWriter, r *http.Request)
+}
+
+// PostRoutes are the post endpoints the router dispatches to.
+type PostRoutes interface {
+	FindAll(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	FindByID(w http.ResponseWriter, r *http.Request)
+	FindBySlug(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
+}
+
+// CategoryRoutes are the category endpoints the router dispatches to.
+type CategoryRoutes interface {
+	GetCategories(w http.ResponseWriter, r *http.Request)
+	GetCategory(w http.ResponseWriter, r *http.Request)
+	CreateCategory(w http.ResponseWriter, r *http.Request)
+	UpdateCategory(w http.ResponseWriter, r *http.Request)
+	DeleteCategory(w http.ResponseWriter, r *http.Request)
+	RestoreCategory(w http.ResponseWriter, r *http.Request)
+}
+
+// routes is everything the router needs. Keeping it apart from NewServer lets
+// the route table and its access rules be tested without a database.
+type routes struct {
+	users        UserRoutes
+	posts        PostRoutes
+	categories   CategoryRoutes
+	authRequired Middleware
+	jwks         func() jwt.JWKS
+}
+
+func newRouter(rt routes) *chi.Mux {
+	r := chi.NewRouter()
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		resp.WriteError(w, resp.NewError(http.StatusNotFound, "route not found"))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		resp.WriteError(w, resp.NewError(http.StatusMethodNotAllowed, "method not allowed"))
+	})
+
+	// Public routes
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		resp.WriteJSON(w, http.StatusOK, "Pong")
+	})
+
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		resp.WriteJSON(w, http.StatusOK, rt.jwks())
+	})
+
+	r.Route("/users", func(r chi.Router) {
+		r.Post("/register", rt.users.Register)
+		r.Post("/login", rt.users.Login)
+		r.Post("/login/mfa", rt.users.LoginMFA)
+		r.Post("/refresh", rt.users.Refresh)
+		r.Post("/verify", rt.users.VerifyEmail)
+		r.Post("/resend-verification", rt.users.ResendVerification)
+		r.Post("/password/forgot", rt.users.ForgotPassword)
+		r.Post("/password/reset", rt.users.ResetPassword)
+		r.Post("/email/confirm", rt.users.ConfirmEmailChange)
+		r.Get("/oauth/{provider}", rt.users.OAuthStart)
+		r.Get("/oauth/{provider}/callback", rt.users.OAuthCallback)
+
+		r.Group(func(r chi.Router) {
+			r.Use(rt.authRequired)
+
+			r.Post("/logout", rt.users.Logout)
+			r.Post("/logout-all", rt.users.LogoutAll)
+			r.Post("/mfa/totp/enroll", rt.users.EnrollTOTP)
+			r.Post("/mfa/totp/confirm", rt.users.ConfirmTOTP)
+			r.Post("/mfa/totp/disable", rt.users.DisableTOTP)
+
+			r.Get("/me", rt.users.Me)
+			r.Patch("/me", rt.users.UpdateProfile)
+			r.Delete("/me", rt.users.DeleteAccount)
+			r.Post("/me/email", rt.users.ChangeEmail)
+			r.Post("/me/password", rt.users.ChangePassword)
+		})
+	})
+
+	r.Route("/admin/users", func(r chi.Router) {
+		r.Use(rt.authRequired)
+		r.Use(auth.RequirePermission(entity.PermissionUserManage))
+
+		r.Get("/", rt.users.ListUsers)
+		r.Get("/{id}", rt.users.GetUser)
+		r.Delete("/{id}", rt.users.DeleteUser)
+		r.Post("/{id}/disable", rt.users.DisableUser)
+		r.Post("/{id}/enable", rt.users.EnableUser)
+		r.Post("/{id}/restore", rt.users.RestoreUser)
+		r.Post("/{id}/revoke-sessions", rt.users.RevokeUserSessions)
+	})
+
+	// Authenticated routes
+	r.Group(func(r chi.Router) {
+		r.Use(rt.authRequired)
+
+		// Posts
+		r.Route("/posts", func(r chi.Router) {
+			r.Get("/", rt.posts.FindAll)
+			r.Post("/", rt.posts.Create)
+			r.Get("/{id}", rt.posts.FindByID)
+			r.Get("/slug/{slug}", rt.posts.FindBySlug)
+			r.Put("/{id}", rt.posts.Update)
+			r.Delete("/{id}", rt.posts.Delete)
+			r.Post("/{id}/restore", rt.posts.Restore)
+		})
+
+		// Categories
+		r.Route("/category", func(r chi.Router) {
+			r.Get("/", rt.categories.GetCategories)
+			r.Get("/{id}", rt.categories.GetCategory)
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequirePermission(entity.PermissionCategoryWrite))
+
+				r.Post("/", rt.categories.CreateCategory)
+				r.Put("/{id}", rt.categories.UpdateCategory)
+				r.Delete("/{id}", rt.categories.DeleteCategory)
+				r.Post("/{id}/restore", rt.categories.RestoreCategory)
+			})
+		})
+	})
+
+	return r
+}
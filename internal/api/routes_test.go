@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/encrypt"
+	"net-http-boilerplate/internal/pkg/jwt"
+	"net-http-boilerplate/internal/pkg/revocation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rolePermissions stands in for the role_permissions table.
+var rolePermissions = map[string][]string{
+	"admin":  {entity.PermissionCategoryWrite, entity.PermissionUserManage},
+	"editor": {entity.PermissionCategoryWrite},
+	"user":   nil,
+}
+
+type fakePermissions struct{}
+
+func (fakePermissions) PermissionsForRoles(ctx context.Context, roles []string) ([]string, error) {
+	var permissions []string
+	for _, role := range roles {
+		permissions = append(permissions, rolePermissions[role]...)
+	}
+	return permissions, nil
+}
+
+type fakeAccounts struct{}
+
+func (fakeAccounts) IsDisabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+// fakeHandlers answers every route with the name of the handler it reached.
+type fakeHandlers struct{}
+
+func reached(w http.ResponseWriter, handler string) {
+	w.Header().Set("X-Handler", handler)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (fakeHandlers) Register(w http.ResponseWriter, r *http.Request)    { reached(w, "Register") }
+func (fakeHandlers) Login(w http.ResponseWriter, r *http.Request)       { reached(w, "Login") }
+func (fakeHandlers) LoginMFA(w http.ResponseWriter, r *http.Request)    { reached(w, "LoginMFA") }
+func (fakeHandlers) Refresh(w http.ResponseWriter, r *http.Request)     { reached(w, "Refresh") }
+func (fakeHandlers) Logout(w http.ResponseWriter, r *http.Request)      { reached(w, "Logout") }
+func (fakeHandlers) LogoutAll(w http.ResponseWriter, r *http.Request)   { reached(w, "LogoutAll") }
+func (fakeHandlers) VerifyEmail(w http.ResponseWriter, r *http.Request) { reached(w, "VerifyEmail") }
+func (fakeHandlers) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	reached(w, "ResendVerification")
+}
+func (fakeHandlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	reached(w, "ForgotPassword")
+}
+func (fakeHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	reached(w, "ResetPassword")
+}
+func (fakeHandlers) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	reached(w, "ConfirmEmailChange")
+}
+func (fakeHandlers) OAuthStart(w http.ResponseWriter, r *http.Request) { reached(w, "OAuthStart") }
+func (fakeHandlers) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	reached(w, "OAuthCallback")
+}
+func (fakeHandlers) EnrollTOTP(w http.ResponseWriter, r *http.Request)  { reached(w, "EnrollTOTP") }
+func (fakeHandlers) ConfirmTOTP(w http.ResponseWriter, r *http.Request) { reached(w, "ConfirmTOTP") }
+func (fakeHandlers) DisableTOTP(w http.ResponseWriter, r *http.Request) { reached(w, "DisableTOTP") }
+func (fakeHandlers) Me(w http.ResponseWriter, r *http.Request)          { reached(w, "Me") }
+func (fakeHandlers) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	reached(w, "UpdateProfile")
+}
+func (fakeHandlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	reached(w, "DeleteAccount")
+}
+func (fakeHandlers) ChangeEmail(w http.ResponseWriter, r *http.Request) { reached(w, "ChangeEmail") }
+func (fakeHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	reached(w, "ChangePassword")
+}
+func (fakeHandlers) ListUsers(w http.ResponseWriter, r *http.Request)   { reached(w, "ListUsers") }
+func (fakeHandlers) GetUser(w http.ResponseWriter, r *http.Request)     { reached(w, "GetUser") }
+func (fakeHandlers) DeleteUser(w http.ResponseWriter, r *http.Request)  { reached(w, "DeleteUser") }
+func (fakeHandlers) DisableUser(w http.ResponseWriter, r *http.Request) { reached(w, "DisableUser") }
+func (fakeHandlers) EnableUser(w http.ResponseWriter, r *http.Request)  { reached(w, "EnableUser") }
+func (fakeHandlers) RestoreUser(w http.ResponseWriter, r *http.Request) { reached(w, "RestoreUser") }
+func (fakeHandlers) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	reached(w, "RevokeUserSessions")
+}
+
+func (fakeHandlers) FindAll(w http.ResponseWriter, r *http.Request)  { reached(w, "posts.FindAll") }
+func (fakeHandlers) Create(w http.ResponseWriter, r *http.Request)   { reached(w, "posts.Create") }
+func (fakeHandlers) FindByID(w http.ResponseWriter, r *http.Request) { reached(w, "posts.FindByID") }
+func (fakeHandlers) FindBySlug(w http.ResponseWriter, r *http.Request) {
+	reached(w, "posts.FindBySlug")
+}
+func (fakeHandlers) Update(w http.ResponseWriter, r *http.Request)  { reached(w, "posts.Update") }
+func (fakeHandlers) Delete(w http.ResponseWriter, r *http.Request)  { reached(w, "posts.Delete") }
+func (fakeHandlers) Restore(w http.ResponseWriter, r *http.Request) { reached(w, "posts.Restore") }
+
+func (fakeHandlers) GetCategories(w http.ResponseWriter, r *http.Request) {
+	reached(w, "GetCategories")
+}
+func (fakeHandlers) GetCategory(w http.ResponseWriter, r *http.Request) { reached(w, "GetCategory") }
+func (fakeHandlers) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	reached(w, "CreateCategory")
+}
+func (fakeHandlers) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	reached(w, "UpdateCategory")
+}
+func (fakeHandlers) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	reached(w, "DeleteCategory")
+}
+func (fakeHandlers) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	reached(w, "RestoreCategory")
+}
+
+func newTestRouter(t *testing.T) (http.Handler, *jwt.JWT) {
+	t.Helper()
+
+	if err := encrypt.Init("test-salt", "test-salt-iv", "aes-256-cbc"); err != nil {
+		t.Fatal(err)
+	}
+
+	jwtService := jwt.NewJWT(config.JWT{Secret: "test-secret", AccessTTL: time.Hour, RefreshTTL: time.Hour})
+	middleware := auth.NewMiddleware(jwtService, revocation.NewMemoryStore(), fakePermissions{}, fakeAccounts{})
+
+	return newRouter(routes{
+		users:        fakeHandlers{},
+		posts:        fakeHandlers{},
+		categories:   fakeHandlers{},
+		authRequired: middleware.AuthRequired,
+		jwks:         jwtService.JWKS,
+	}), jwtService
+}
+
+func TestRoutes(t *testing.T) {
+	router, jwtService := newTestRouter(t)
+
+	id := uuid.NewString()
+	tests := []struct {
+		method  string
+		path    string
+		role    string // empty sends no token
+		status  int
+		handler string
+	}{
+		// Public routes
+		{http.MethodGet, "/", "", http.StatusOK, ""},
+		{http.MethodGet, "/.well-known/jwks.json", "", http.StatusOK, ""},
+		{http.MethodPost, "/users/register", "", http.StatusOK, "Register"},
+		{http.MethodPost, "/users/login", "", http.StatusOK, "Login"},
+		{http.MethodPost, "/users/login/mfa", "", http.StatusOK, "LoginMFA"},
+		{http.MethodPost, "/users/refresh", "", http.StatusOK, "Refresh"},
+		{http.MethodPost, "/users/verify", "", http.StatusOK, "VerifyEmail"},
+		{http.MethodPost, "/users/resend-verification", "", http.StatusOK, "ResendVerification"},
+		{http.MethodPost, "/users/password/forgot", "", http.StatusOK, "ForgotPassword"},
+		{http.MethodPost, "/users/password/reset", "", http.StatusOK, "ResetPassword"},
+		{http.MethodPost, "/users/email/confirm", "", http.StatusOK, "ConfirmEmailChange"},
+		{http.MethodGet, "/users/oauth/google", "", http.StatusOK, "OAuthStart"},
+		{http.MethodGet, "/users/oauth/google/callback", "", http.StatusOK, "OAuthCallback"},
+
+		// Signed-in user routes
+		{http.MethodPost, "/users/logout", "", http.StatusUnauthorized, ""},
+		{http.MethodPost, "/users/logout", "user", http.StatusOK, "Logout"},
+		{http.MethodPost, "/users/logout-all", "user", http.StatusOK, "LogoutAll"},
+		{http.MethodPost, "/users/mfa/totp/enroll", "", http.StatusUnauthorized, ""},
+		{http.MethodPost, "/users/mfa/totp/enroll", "user", http.StatusOK, "EnrollTOTP"},
+		{http.MethodPost, "/users/mfa/totp/confirm", "user", http.StatusOK, "ConfirmTOTP"},
+		{http.MethodPost, "/users/mfa/totp/disable", "user", http.StatusOK, "DisableTOTP"},
+		{http.MethodGet, "/users/me", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/users/me", "user", http.StatusOK, "Me"},
+		{http.MethodPatch, "/users/me", "user", http.StatusOK, "UpdateProfile"},
+		{http.MethodDelete, "/users/me", "user", http.StatusOK, "DeleteAccount"},
+		{http.MethodPost, "/users/me/email", "user", http.StatusOK, "ChangeEmail"},
+		{http.MethodPost, "/users/me/password", "user", http.StatusOK, "ChangePassword"},
+
+		// Admin routes need user:manage
+		{http.MethodGet, "/admin/users", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/admin/users", "user", http.StatusForbidden, ""},
+		{http.MethodGet, "/admin/users", "editor", http.StatusForbidden, ""},
+		{http.MethodGet, "/admin/users", "admin", http.StatusOK, "ListUsers"},
+		{http.MethodGet, "/admin/users/" + id, "user", http.StatusForbidden, ""},
+		{http.MethodGet, "/admin/users/" + id, "admin", http.StatusOK, "GetUser"},
+		{http.MethodDelete, "/admin/users/" + id, "user", http.StatusForbidden, ""},
+		{http.MethodDelete, "/admin/users/" + id, "admin", http.StatusOK, "DeleteUser"},
+		{http.MethodPost, "/admin/users/" + id + "/disable", "user", http.StatusForbidden, ""},
+		{http.MethodPost, "/admin/users/" + id + "/disable", "admin", http.StatusOK, "DisableUser"},
+		{http.MethodPost, "/admin/users/" + id + "/enable", "admin", http.StatusOK, "EnableUser"},
+		{http.MethodPost, "/admin/users/" + id + "/restore", "admin", http.StatusOK, "RestoreUser"},
+		{http.MethodPost, "/admin/users/" + id + "/revoke-sessions", "user", http.StatusForbidden, ""},
+		{http.MethodPost, "/admin/users/" + id + "/revoke-sessions", "admin", http.StatusOK, "RevokeUserSessions"},
+
+		// Posts need a signed-in user; ownership is checked by the service
+		{http.MethodGet, "/posts", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/posts", "user", http.StatusOK, "posts.FindAll"},
+		{http.MethodPost, "/posts", "user", http.StatusOK, "posts.Create"},
+		{http.MethodGet, "/posts/1", "user", http.StatusOK, "posts.FindByID"},
+		{http.MethodGet, "/posts/slug/hello-world", "user", http.StatusOK, "posts.FindBySlug"},
+		{http.MethodPut, "/posts/1", "user", http.StatusOK, "posts.Update"},
+		{http.MethodDelete, "/posts/1", "user", http.StatusOK, "posts.Delete"},
+		{http.MethodPost, "/posts/1/restore", "user", http.StatusOK, "posts.Restore"},
+
+		// Categories are readable by any user, writable with category:write
+		{http.MethodGet, "/category", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/category", "user", http.StatusOK, "GetCategories"},
+		{http.MethodGet, "/category/1", "user", http.StatusOK, "GetCategory"},
+		{http.MethodPost, "/category", "user", http.StatusForbidden, ""},
+		{http.MethodPost, "/category", "editor", http.StatusOK, "CreateCategory"},
+		{http.MethodPost, "/category", "admin", http.StatusOK, "CreateCategory"},
+		{http.MethodPut, "/category/1", "user", http.StatusForbidden, ""},
+		{http.MethodPut, "/category/1", "editor", http.StatusOK, "UpdateCategory"},
+		{http.MethodDelete, "/category/1", "user", http.StatusForbidden, ""},
+		{http.MethodDelete, "/category/1", "editor", http.StatusOK, "DeleteCategory"},
+		{http.MethodPost, "/category/1/restore", "user", http.StatusForbidden, ""},
+		{http.MethodPost, "/category/1/restore", "editor", http.StatusOK, "RestoreCategory"},
+
+		// Unknown routes and methods
+		{http.MethodGet, "/categories", "admin", http.StatusNotFound, ""},
+		{http.MethodGet, "/users/register", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		name := tt.method + " " + tt.path
+		if tt.role != "" {
+			name += " as " + tt.role
+		}
+
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.role != "" {
+				token, _, err := jwtService.GenerateToken(uuid.NewString(), "test@example.com", []string{tt.role})
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+
+			if got := rec.Header().Get("X-Handler"); got != tt.handler {
+				t.Errorf("handler = %q, want %q", got, tt.handler)
+			}
+		})
+	}
+}
+
+func TestRoutesRejectBadTokens(t *testing.T) {
+	router, jwtService := newTestRouter(t)
+
+	_, refresh, err := jwtService.GenerateToken(uuid.NewString(), "test@example.com", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfa, err := jwtService.GenerateMFAToken(uuid.NewString(), "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing bearer prefix", "token"},
+		{"empty token", "Bearer "},
+		{"malformed token", "Bearer not-a-jwt"},
+		{"refresh token", "Bearer " + refresh},
+		{"mfa challenge token", "Bearer " + mfa},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.Header.Set("Authorization", tt.authorization)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -3,13 +3,19 @@ package api
 import (
 	"context"
 	"fmt"
-	"net-http-boilerplate/internal/api/resp"
+	"net"
 	"net-http-boilerplate/internal/auth"
 	"net-http-boilerplate/internal/category"
 	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/pkg/cursor"
 	"net-http-boilerplate/internal/pkg/encrypt"
 	"net-http-boilerplate/internal/pkg/jwt"
+	"net-http-boilerplate/internal/pkg/lockout"
+	"net-http-boilerplate/internal/pkg/mailer"
 	"net-http-boilerplate/internal/pkg/postgres"
+	"net-http-boilerplate/internal/pkg/redis"
+	"net-http-boilerplate/internal/pkg/revocation"
+	"net-http-boilerplate/internal/pkg/securetoken"
 	"net-http-boilerplate/internal/pkg/validator"
 	"net-http-boilerplate/internal/post"
 	"net-http-boilerplate/internal/user"
@@ -36,74 +42,83 @@ func NewServer() *Server {
 	// validator
 	validator := validator.NewValidator()
 
+	// Proxies allowed to report the client IP
+	trustedProxies, err := parseTrustedProxies(cfg.HTTP.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to parse TRUSTED_PROXIES")
+	}
+
 	// database
 	db := postgres.NewGORM(&cfg.Database)
-	postgres.Migrate(db)
+	if cfg.Database.AutoMigrate {
+		postgres.Migrate(db)
+	}
 
 	// Initialize JWT service
 	jwtService := jwt.NewJWT(cfg.JWT)
 
-	// Initialize auth middleware
-	authMiddleware := auth.NewMiddleware(jwtService)
+	// Token revocation and login lockouts, fall back to in-memory stores
+	// without Redis
+	var revocationStore revocation.Store
+	var lockoutStore lockout.Store
+	if cfg.Redis.URL != "" {
+		redisClient := redis.New(cfg.Redis.URL)
+		revocationStore = revocation.NewRedisStore(redisClient)
+		lockoutStore = lockout.NewRedisStore(redisClient)
+	} else {
+		log.Warn().Msg("REDIS_URL is not set, using in-memory revocation and lockout stores")
+		revocationStore = revocation.NewMemoryStore()
+		lockoutStore = lockout.NewMemoryStore()
+	}
+	loginGuard := lockout.NewGuard(lockoutStore, cfg.Lockout)
+
+	// Mailer and single-use email tokens
+	mail := mailer.New(cfg.Mail)
+	tokens := securetoken.New(cfg.AppConfig.AppSalt)
+
+	// Signs the opaque cursors of paginated lists
+	cursors := cursor.New(cfg.AppConfig.AppSalt)
 
 	// Repo
 	userRepo := user.NewUserRepository(db)
-	postRepo := post.NewPostRepository(db)
+	postRepo := post.NewPostRepository(db, cfg.Search)
 	categoryRepo := category.NewCategoryRepository(db)
 
+	// Initialize auth middleware
+	authMiddleware := auth.NewMiddleware(jwtService, revocationStore, userRepo, userRepo)
+
 	// Service
-	userService := user.NewUserService(userRepo, jwtService)
+	userService := user.NewUserService(userRepo, jwtService, revocationStore, mail, tokens, loginGuard, cfg)
 	postService := post.NewPostService(postRepo)
 	categoryService := category.NewCategoryService(categoryRepo)
 
 	// Handler
-	userHandler := user.NewUserHandler(userService, validator)
-	postHandler := post.NewPostHandler(postService)
-	categoryHandler := category.NewCategoryHandler(categoryService)
-
-	r := chi.NewRouter()
-
-	// Public routes
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		resp.WriteJSON(w, http.StatusOK, "Pong")
-	})
-
-	r.Route("/users", func(r chi.Router) {
-		r.Post("/register", userHandler.Register)
-		r.Post("/login", userHandler.Login)
-	})
-
-	// Authenticated routes
-	r.Group(func(r chi.Router) {
-		r.Use(authMiddleware.AuthRequired)
-
-		// Posts
-		r.Route("/posts", func(r chi.Router) {
-			r.Get("/", postHandler.FindAll)
-			r.Post("/", postHandler.Create)
-			r.Get("/{id}", postHandler.FindByID)
-			r.Put("/{id}", postHandler.Update)
-			r.Delete("/{id}", postHandler.Delete)
-		})
-
-		// Categories
-		r.Route("/category", func(r chi.Router) {
-			r.Get("/", categoryHandler.GetCategories)
-			r.Get("/{id}", categoryHandler.GetCategory)
-			r.Post("/", categoryHandler.CreateCategory)
-			r.Put("/{id}", categoryHandler.UpdateCategory)
-			r.Delete("/{id}", categoryHandler.DeleteCategory)
-		})
+	userHandler := user.NewUserHandler(userService, validator, cursors)
+	postHandler := post.NewPostHandler(postService, validator, cursors)
+	categoryHandler := category.NewCategoryHandler(categoryService, validator, cursors)
+
+	r := newRouter(routes{
+		users:        userHandler,
+		posts:        postHandler,
+		categories:   categoryHandler,
+		authRequired: authMiddleware.AuthRequired,
+		jwks:         jwtService.JWKS,
 	})
 
 	return &Server{
-		router: r,
+		router:         r,
+		trustedProxies: trustedProxies,
+		purger:         postgres.NewPurger(db, cfg.Purge),
+		scheduler:      post.NewScheduler(postRepo, cfg.Publishing),
 	}
 
 }
 
 type Server struct {
-	router *chi.Mux
+	router         *chi.Mux
+	trustedProxies []*net.IPNet
+	purger         *postgres.Purger
+	scheduler      *post.Scheduler
 }
 
 // Run method of the Server struct runs the HTTP server on the specified port. It initializes
@@ -115,7 +130,7 @@ func (s *Server) Run(port int) {
 		s.router,
 		recoverHandler,
 		loggerHandler(func(w http.ResponseWriter, r *http.Request) bool { return r.URL.Path == "/" }),
-		realIPHandler,
+		realIPHandler(s.trustedProxies),
 		requestIDHandler,
 		corsHandler,
 	)
@@ -127,6 +142,10 @@ func (s *Server) Run(port int) {
 		WriteTimeout: 60 * time.Second,
 	}
 
+	jobs, stopJobs := context.WithCancel(context.Background())
+	go s.purger.Run(jobs)
+	go s.scheduler.Run(jobs)
+
 	done := make(chan bool)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -134,6 +153,7 @@ func (s *Server) Run(port int) {
 	go func() {
 		<-quit
 		log.Info().Msg("Server is shutting down...")
+		stopJobs()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
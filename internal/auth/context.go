@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+var principalKey = contextKey{}
+
+// Principal is the authenticated user behind a request, taken from a
+// validated access token by AuthRequired.
+type Principal struct {
+	ID          uuid.UUID
+	Email       string
+	Roles       []string
+	Permissions []string
+	TokenID     string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// WithUser returns a copy of ctx carrying the principal.
+func WithUser(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// UserFromContext returns the principal stored by WithUser, if any.
+func UserFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
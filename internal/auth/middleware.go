@@ -4,16 +4,38 @@ import (
 	"context"
 	"net-http-boilerplate/internal/api/resp"
 	"net-http-boilerplate/internal/pkg/jwt"
+	"net-http-boilerplate/internal/pkg/revocation"
 	"net/http"
 	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
+// PermissionStore resolves the permissions granted by a set of role names.
+type PermissionStore interface {
+	PermissionsForRoles(ctx context.Context, roles []string) ([]string, error)
+}
+
+// AccountStore reports whether an account has been disabled by an admin.
+type AccountStore interface {
+	IsDisabled(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
 type MiddlewareService struct {
-	jwtService *jwt.JWT
+	jwtService  *jwt.JWT
+	revocation  revocation.Store
+	permissions PermissionStore
+	accounts    AccountStore
 }
 
-func NewMiddleware(jwtService *jwt.JWT) *MiddlewareService {
-	return &MiddlewareService{jwtService: jwtService}
+func NewMiddleware(jwtService *jwt.JWT, revocation revocation.Store, permissions PermissionStore, accounts AccountStore) *MiddlewareService {
+	return &MiddlewareService{
+		jwtService:  jwtService,
+		revocation:  revocation,
+		permissions: permissions,
+		accounts:    accounts,
+	}
 }
 
 func (m *MiddlewareService) AuthRequired(next http.Handler) http.Handler {
@@ -21,34 +43,122 @@ func (m *MiddlewareService) AuthRequired(next http.Handler) http.Handler {
 		ctx := r.Context()
 		tokenString := r.Header.Get("Authorization")
 		if tokenString == "" {
-			resp.WriteJSON(w, http.StatusUnauthorized, map[string]string{"message": "Token is missing"})
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Token is missing"))
 			return
 		}
 
 		const bearerPrefix = "Bearer "
 		if !strings.HasPrefix(tokenString, bearerPrefix) {
-			resp.WriteJSON(w, http.StatusUnauthorized, map[string]string{"message": "Token must start with Bearer"})
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Token must start with Bearer"))
 			return
 		}
 
 		token := strings.TrimPrefix(tokenString, bearerPrefix)
 		if token == "" {
-			resp.WriteJSON(w, http.StatusUnauthorized, map[string]interface{}{
-				"message": "Token is missing",
-			})
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Token is missing"))
+			return
+		}
+
+		claims, err := m.jwtService.ParseAccessToken(token)
+		if err != nil {
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Invalid token"))
+			return
+		}
+
+		revoked, err := m.isRevoked(ctx, claims)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to check token revocation")
+			resp.WriteError(w, err)
+			return
+		}
+
+		if revoked {
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Token has been revoked"))
+			return
+		}
+
+		userID, err := uuid.Parse(claims.ID)
+		if err != nil {
+			resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Invalid token"))
+			return
+		}
+
+		// Disabling also revokes sessions; this catches tokens whose
+		// revocation could not be recorded.
+		disabled, err := m.accounts.IsDisabled(ctx, userID)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to check account status")
+			resp.WriteError(w, err)
+			return
+		}
+
+		if disabled {
+			resp.WriteError(w, resp.NewError(http.StatusForbidden, "Account is disabled"))
 			return
 		}
 
-		claims, err := m.jwtService.ParseToken(token)
+		permissions, err := m.permissions.PermissionsForRoles(ctx, claims.Roles)
 		if err != nil {
-			resp.WriteJSON(w, http.StatusUnauthorized, map[string]interface{}{
-				"message": "Invalid token",
-			})
+			log.Ctx(ctx).Error().Err(err).Msg("failed to resolve permissions")
+			resp.WriteError(w, err)
 			return
 		}
 
-		ctx = context.WithValue(ctx, "user", claims)
+		ctx = WithUser(ctx, &Principal{
+			ID:          userID,
+			Email:       claims.Email,
+			Roles:       claims.Roles,
+			Permissions: permissions,
+			TokenID:     claims.RegisteredClaims.ID,
+			IssuedAt:    claims.IssuedAt.Time,
+			ExpiresAt:   claims.ExpiresAt.Time,
+		})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 
 }
+
+// RequirePermission only lets the request through when the authenticated
+// principal has the given permission. It must run after AuthRequired.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := UserFromContext(r.Context())
+			if !ok {
+				resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "Token is missing"))
+				return
+			}
+
+			if !principal.HasPermission(permission) {
+				resp.WriteError(w, resp.NewError(http.StatusForbidden, "Permission denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isRevoked reports whether the token was revoked on its own (logout) or was
+// issued before the user revoked all of their sessions (logout-all).
+func (m *MiddlewareService) isRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	if claims.RegisteredClaims.ID == "" {
+		return true, nil
+	}
+
+	revoked, err := m.revocation.IsTokenRevoked(ctx, claims.RegisteredClaims.ID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	revokedAt, err := m.revocation.UserRevokedAt(ctx, claims.ID)
+	if err != nil {
+		return false, err
+	}
+
+	// A token issued in the same millisecond as the revocation, or the same
+	// second for tokens without iat_ms, cannot be told apart from one issued
+	// just before it and is rejected as well.
+	issuedAt, precision := claims.Issued()
+	return !revokedAt.IsZero() && !issuedAt.After(revokedAt.Truncate(precision)), nil
+}
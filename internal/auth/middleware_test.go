@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/pkg/encrypt"
+	"net-http-boilerplate/internal/pkg/jwt"
+	"net-http-boilerplate/internal/pkg/revocation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestIsRevoked(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.NewString()
+	cutoff := time.Date(2024, 1, 2, 15, 4, 5, 500*int(time.Millisecond), time.UTC)
+
+	claims := func(issuedAt time.Time, milli bool) *jwt.Claims {
+		c := &jwt.Claims{
+			ID: userID,
+			RegisteredClaims: jwtlib.RegisteredClaims{
+				ID:       uuid.NewString(),
+				IssuedAt: jwtlib.NewNumericDate(issuedAt),
+			},
+		}
+		if milli {
+			c.IssuedAtMilli = issuedAt.UnixMilli()
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		claims  *jwt.Claims
+		revoked bool
+	}{
+		{"issued 1s before revocation", claims(cutoff.Add(-time.Second), true), true},
+		{"issued 1ms before revocation", claims(cutoff.Add(-time.Millisecond), true), true},
+		{"issued in the same millisecond", claims(cutoff, true), true},
+		{"issued 1ms after revocation", claims(cutoff.Add(time.Millisecond), true), false},
+		{"without iat_ms, same second", claims(cutoff.Truncate(time.Second), false), true},
+		{"without iat_ms, next second", claims(cutoff.Truncate(time.Second).Add(time.Second), false), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := revocation.NewMemoryStore()
+			if err := store.RevokeUser(ctx, userID, cutoff, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			m := &MiddlewareService{revocation: store}
+			revoked, err := m.isRevoked(ctx, tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if revoked != tt.revoked {
+				t.Errorf("isRevoked() = %v, want %v", revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestIsRevokedToken(t *testing.T) {
+	ctx := context.Background()
+	store := revocation.NewMemoryStore()
+	m := &MiddlewareService{revocation: store}
+
+	c := &jwt.Claims{ID: uuid.NewString(), RegisteredClaims: jwtlib.RegisteredClaims{ID: uuid.NewString()}}
+
+	if revoked, err := m.isRevoked(ctx, c); err != nil || revoked {
+		t.Fatalf("isRevoked() = (%v, %v), want (false, nil)", revoked, err)
+	}
+
+	if err := store.RevokeToken(ctx, c.RegisteredClaims.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := m.isRevoked(ctx, c); err != nil || !revoked {
+		t.Errorf("isRevoked() of a revoked jti = (%v, %v), want (true, nil)", revoked, err)
+	}
+
+	c.RegisteredClaims.ID = ""
+	if revoked, err := m.isRevoked(ctx, c); err != nil || !revoked {
+		t.Errorf("isRevoked() without a jti = (%v, %v), want (true, nil)", revoked, err)
+	}
+}
+
+type fakePermissions struct{}
+
+func (fakePermissions) PermissionsForRoles(ctx context.Context, roles []string) ([]string, error) {
+	return nil, nil
+}
+
+type fakeAccounts struct{}
+
+func (fakeAccounts) IsDisabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+// TestAuthRequiredSessionRevocation revokes a user's sessions 1ms after an
+// access token was issued, and checks the token no longer gets through.
+func TestAuthRequiredSessionRevocation(t *testing.T) {
+	if err := encrypt.Init("test-salt", "test-salt-iv", "aes-256-cbc"); err != nil {
+		t.Fatal(err)
+	}
+
+	jwtService := jwt.NewJWT(config.JWT{Secret: "test-secret", AccessTTL: time.Hour, RefreshTTL: time.Hour})
+	store := revocation.NewMemoryStore()
+	m := NewMiddleware(jwtService, store, fakePermissions{}, fakeAccounts{})
+	handler := m.AuthRequired(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	userID := uuid.NewString()
+	token, _, err := jwtService.GenerateToken(userID, "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := jwtService.ParseAccessToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedAt, _ := claims.Issued()
+
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("status before revocation = %d, want %d", code, http.StatusOK)
+	}
+
+	if err := store.RevokeUser(context.Background(), userID, issuedAt.Add(time.Millisecond), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := request(); code != http.StatusUnauthorized {
+		t.Errorf("status after revocation = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Policy decides whether a principal may act on a resource. Each resource
+// package defines its own policies on top of the helpers below.
+type Policy[T any] func(p *Principal, resource T) bool
+
+// HasRole reports whether the principal was granted the named role.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether any of the principal's roles grants the
+// named permission.
+func (p *Principal) HasPermission(permission string) bool {
+	return p != nil && slices.Contains(p.Permissions, permission)
+}
+
+// IsAdmin reports whether the principal bypasses ownership checks.
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(entity.RoleAdmin)
+}
+
+// IsOwnerOrAdmin reports whether the principal owns a resource with the given
+// owner, or is an admin. Resources without an owner can only be handled by admins.
+func IsOwnerOrAdmin(p *Principal, ownerID *uuid.UUID) bool {
+	if p == nil {
+		return false
+	}
+
+	if p.IsAdmin() {
+		return true
+	}
+
+	return ownerID != nil && *ownerID == p.ID
+}
+
+// IncludeDeleted reads the include_deleted query flag of a list request.
+// Soft-deleted rows are only visible to admins; anyone else asking for them
+// is refused.
+func IncludeDeleted(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("include_deleted")
+	if raw == "" {
+		return false, nil
+	}
+
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, apperror.Validation("'include_deleted' must be true or false")
+	}
+
+	if include {
+		principal, _ := UserFromContext(r.Context())
+		if !principal.IsAdmin() {
+			return false, apperror.Forbidden("only admins may list deleted records")
+		}
+	}
+
+	return include, nil
+}
@@ -1,14 +1,17 @@
 package category
 
+import "time"
+
 type CreateCategoryRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=100"`
 }
 
 type UpdateCategoryRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=100"`
 }
 
 type CategoryResponse struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
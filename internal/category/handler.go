@@ -2,22 +2,42 @@ package category
 
 import (
 	"encoding/json"
+	"errors"
+	"net-http-boilerplate/internal/api/query"
 	"net-http-boilerplate/internal/api/resp"
-	"net-http-boilerplate/internal/entity"
 	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/cursor"
+	"net-http-boilerplate/internal/pkg/validator"
 	"net/http"
 	"strconv"
 
 	"github.com/rs/zerolog/log"
 )
 
+// sortable maps the accepted sort_by values to columns.
+var sortable = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type httpHandler struct {
-	service *Service
+	service   *Service
+	validator *validator.Validator
+	cursors   *cursor.Codec
+	list      query.Options
 }
 
-func NewCategoryHandler(service *Service) *httpHandler {
+func NewCategoryHandler(service *Service, validator *validator.Validator, cursors *cursor.Codec) *httpHandler {
 	return &httpHandler{
-		service: service,
+		service:   service,
+		validator: validator,
+		cursors:   cursors,
+		list: query.Options{
+			Sortable: sortable,
+			Cursors:  cursors,
+		},
 	}
 }
 
@@ -30,6 +50,11 @@ func (h *httpHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
 	if err := h.service.Create(ctx, &req); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to create category: %s", err)
 		resp.WriteError(w, err)
@@ -42,38 +67,15 @@ func (h *httpHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 
 func (h *httpHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	pageStr := r.URL.Query().Get("page")
-	perPageStr := r.URL.Query().Get("perPage")
-
-	if pageStr == "" {
-		pageStr = "1"
-	}
-	if perPageStr == "" {
-		perPageStr = "10"
-	}
-
-	pageInt, err := strconv.Atoi(pageStr)
+	filter, err := query.ParseFilter(r, h.list)
 	if err != nil {
-		log.Ctx(ctx).Err(err).Msg("invalid 'page' query param")
-		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "'page' must be a number"))
-		return
-	}
-
-	perPageInt, err := strconv.Atoi(perPageStr)
-	if err != nil {
-		log.Ctx(ctx).Err(err).Msg("invalid 'perPage' query param")
-		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "'perPage' must be a number"))
+		resp.WriteError(w, err)
 		return
 	}
 
-	filter := &entity.Filter{
-		Page:    &pageInt,
-		PerPage: &perPageInt,
-	}
-
 	res, stats, err := h.service.FindAll(ctx, filter)
 	if err != nil {
-		if err == apperror.ErrResourceNotFound {
+		if errors.Is(err, apperror.ErrResourceNotFound) {
 			resp.WriteJSONWithPaginateResponse(w, http.StatusOK, "success", res, stats)
 			return
 		}
@@ -83,6 +85,11 @@ func (h *httpHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if filter.Limit != nil {
+		resp.WriteJSONWithCursorResponse(w, http.StatusOK, "success", res, h.cursors.Encode(stats.Next), h.cursors.Encode(stats.Prev))
+		return
+	}
+
 	resp.WriteJSONWithPaginateResponse(w, http.StatusOK, "success", res, stats)
 }
 
@@ -98,12 +105,6 @@ func (h *httpHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
 
 	category, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("category not found")
-			resp.WriteError(w, resp.NewError(http.StatusNotFound, "category not found"))
-			return
-		}
-
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to get category: %s", err)
 		resp.WriteError(w, err)
 		return
@@ -129,14 +130,13 @@ func (h *httpHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
 	res, err := h.service.Update(ctx, id, req)
 	if err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("category not found")
-			resp.WriteError(w, resp.NewError(http.StatusNotFound, "category not found"))
-			return
-		}
-
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to update category: %s", err)
 		resp.WriteError(w, err)
 		return
@@ -156,12 +156,6 @@ func (h *httpHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.Delete(ctx, id); err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("category not found")
-			resp.WriteError(w, resp.NewError(http.StatusNotFound, "category not found"))
-			return
-		}
-
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to delete category: %s", err)
 		resp.WriteError(w, err)
 		return
@@ -169,3 +163,23 @@ func (h *httpHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 
 	resp.WriteSuccess(w, http.StatusOK, "success", nil)
 }
+
+func (h *httpHandler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("bad request, invalid id")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	res, err := h.service.Restore(ctx, id)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("failed to restore category: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", res)
+}
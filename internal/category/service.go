@@ -2,12 +2,18 @@ package category
 
 import (
 	"context"
+	"errors"
 	"net-http-boilerplate/internal/entity"
 	apperror "net-http-boilerplate/internal/pkg/app-error"
 
 	"gorm.io/gorm"
 )
 
+var (
+	errCategoryNotFound = apperror.NotFound("category not found")
+	errCategoryInUse    = apperror.Conflict("category still has posts")
+)
+
 type Service struct {
 	repo Repo
 }
@@ -18,6 +24,9 @@ type Repo interface {
 	FindByID(ctx context.Context, id int) (*entity.Category, error)
 	Update(ctx context.Context, category *entity.Category) error
 	Delete(ctx context.Context, id int) error
+	FindDeletedByID(ctx context.Context, id int) (*entity.Category, error)
+	Restore(ctx context.Context, id int) error
+	HasPosts(ctx context.Context, id int) (bool, error)
 }
 
 func NewCategoryService(repo Repo) *Service {
@@ -37,8 +46,8 @@ func (s *Service) Create(ctx context.Context, req *CreateCategoryRequest) error
 func (s *Service) FindAll(ctx context.Context, filter *entity.Filter) ([]CategoryResponse, *entity.Stats, error) {
 	categories, stats, err := s.repo.FindAll(ctx, filter)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, stats, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, stats, errCategoryNotFound
 		}
 		return nil, stats, err
 	}
@@ -46,8 +55,9 @@ func (s *Service) FindAll(ctx context.Context, filter *entity.Filter) ([]Categor
 	var response []CategoryResponse
 	for _, category := range categories {
 		response = append(response, CategoryResponse{
-			ID:   category.ID,
-			Name: category.Name,
+			ID:        category.ID,
+			Name:      category.Name,
+			DeletedAt: entity.DeletedTime(category.DeletedAt),
 		})
 	}
 
@@ -57,9 +67,10 @@ func (s *Service) FindAll(ctx context.Context, filter *entity.Filter) ([]Categor
 func (s *Service) FindByID(ctx context.Context, id int) (*entity.Category, error) {
 	category, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCategoryNotFound
 		}
+		return nil, err
 	}
 
 	return category, nil
@@ -68,8 +79,8 @@ func (s *Service) FindByID(ctx context.Context, id int) (*entity.Category, error
 func (s *Service) Update(ctx context.Context, id int, req UpdateCategoryRequest) (*CategoryResponse, error) {
 	existing, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCategoryNotFound
 		}
 
 		return nil, err
@@ -90,10 +101,40 @@ func (s *Service) Update(ctx context.Context, id int, req UpdateCategoryRequest)
 func (s *Service) Delete(ctx context.Context, id int) error {
 	cat, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errCategoryNotFound
 		}
+		return err
+	}
+
+	// Posts keep pointing at a soft-deleted category, so it could never be
+	// purged while they exist.
+	inUse, err := s.repo.HasPosts(ctx, cat.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errCategoryInUse
 	}
 
 	return s.repo.Delete(ctx, cat.ID)
 }
+
+func (s *Service) Restore(ctx context.Context, id int) (*CategoryResponse, error) {
+	deleted, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCategoryNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.repo.Restore(ctx, deleted.ID); err != nil {
+		return nil, err
+	}
+
+	return &CategoryResponse{
+		ID:   deleted.ID,
+		Name: deleted.Name,
+	}, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -11,8 +12,16 @@ type Config struct {
 	Database    Database
 	JWT         JWT
 	AppConfig   AppConfig
+	HTTP        HTTP
 	ChunkUpload ChunkUploadConfig
 	Redis       Redis
+	Mail        Mail
+	User        User
+	OAuth       OAuth
+	Lockout     Lockout
+	Purge       Purge
+	Search      Search
+	Publishing  Publishing
 }
 
 func Load() *Config {
@@ -30,11 +39,22 @@ type Database struct {
 	User     string `env:"DB_USER"`
 	Password string `env:"DB_PASSWORD"`
 	Name     string `env:"DB_NAME"`
+	// AutoMigrate applies pending migrations when the API starts. Disable it
+	// to run `migrate up` as a separate deploy step instead.
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE" envDefault:"true"`
 }
 
 type JWT struct {
-	Secret string `env:"JWT_SECRET"`
-	Issuer string `env:"JWT_ISSUER"`
+	Secret        string        `env:"JWT_SECRET"`
+	RefreshSecret string        `env:"JWT_REFRESH_SECRET"`
+	Issuer        string        `env:"JWT_ISSUER"`
+	Audience      string        `env:"JWT_AUDIENCE"`
+	AccessTTL     time.Duration `env:"JWT_ACCESS_TTL" envDefault:"24h"`
+	RefreshTTL    time.Duration `env:"JWT_REFRESH_TTL" envDefault:"168h"`
+	MFATTL        time.Duration `env:"JWT_MFA_TTL" envDefault:"5m"`
+	Algorithm     string        `env:"JWT_ALGORITHM" envDefault:"HS256"`
+	KeysDir       string        `env:"JWT_KEYS_DIR"`
+	ActiveKeyID   string        `env:"JWT_ACTIVE_KEY_ID"`
 }
 
 type AppConfig struct {
@@ -43,6 +63,13 @@ type AppConfig struct {
 	AppEncryptMethod string `env:"APP_ENCRYPT_METHOD"`
 }
 
+// HTTP lists the reverse proxies, as IPs or CIDRs, whose X-Forwarded-For,
+// X-Real-IP and True-Client-IP headers are trusted. Without any, the client
+// IP is always the socket address.
+type HTTP struct {
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+}
+
 type ChunkUploadConfig struct {
 	MaxChunkSize   int64  `env:"CHUNK_SIZE"`
 	StoragePath    string `env:"STORAGE_PATH"`
@@ -53,6 +80,74 @@ type Redis struct {
 	URL string `env:"REDIS_URL"`
 }
 
+type Mail struct {
+	Driver   string `env:"MAIL_DRIVER" envDefault:"log"`
+	Host     string `env:"MAIL_HOST"`
+	Port     int    `env:"MAIL_PORT" envDefault:"587"`
+	Username string `env:"MAIL_USERNAME"`
+	Password string `env:"MAIL_PASSWORD"`
+	From     string `env:"MAIL_FROM"`
+}
+
+type User struct {
+	RequireVerifiedEmail bool          `env:"USER_REQUIRE_VERIFIED_EMAIL" envDefault:"true"`
+	VerificationTokenTTL time.Duration `env:"USER_VERIFICATION_TOKEN_TTL" envDefault:"24h"`
+	VerificationURL      string        `env:"USER_VERIFICATION_URL"`
+	PasswordResetTTL     time.Duration `env:"USER_PASSWORD_RESET_TTL" envDefault:"1h"`
+	PasswordResetURL     string        `env:"USER_PASSWORD_RESET_URL"`
+	EmailChangeURL       string        `env:"USER_EMAIL_CHANGE_URL"`
+	TOTPIssuer           string        `env:"USER_TOTP_ISSUER" envDefault:"net-http-boilerplate"`
+}
+
+type OAuth struct {
+	StateTTL time.Duration `env:"OAUTH_STATE_TTL" envDefault:"10m"`
+	Google   OAuthProvider `envPrefix:"OAUTH_GOOGLE_"`
+	GitHub   OAuthProvider `envPrefix:"OAUTH_GITHUB_"`
+}
+
+// OAuthProvider endpoints default to the provider's public ones when empty.
+// Providers with an Issuer and JWKSURL are OpenID Connect providers, whose
+// logins are checked against their signed ID token.
+type OAuthProvider struct {
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	RedirectURL  string   `env:"REDIRECT_URL"`
+	AuthURL      string   `env:"AUTH_URL"`
+	TokenURL     string   `env:"TOKEN_URL"`
+	UserInfoURL  string   `env:"USERINFO_URL"`
+	EmailsURL    string   `env:"EMAILS_URL"`
+	Issuer       string   `env:"ISSUER"`
+	JWKSURL      string   `env:"JWKS_URL"`
+	Scopes       []string `env:"SCOPES" envSeparator:","`
+}
+
+type Lockout struct {
+	MaxAccountAttempts int           `env:"LOGIN_MAX_ACCOUNT_ATTEMPTS" envDefault:"5"`
+	MaxIPAttempts      int           `env:"LOGIN_MAX_IP_ATTEMPTS" envDefault:"20"`
+	Window             time.Duration `env:"LOGIN_ATTEMPT_WINDOW" envDefault:"15m"`
+	BaseLockout        time.Duration `env:"LOGIN_LOCKOUT_BASE" envDefault:"1m"`
+	MaxLockout         time.Duration `env:"LOGIN_LOCKOUT_MAX" envDefault:"1h"`
+}
+
+// Purge controls how long soft-deleted rows are kept. A zero retention keeps
+// them forever.
+type Purge struct {
+	Retention time.Duration `env:"SOFT_DELETE_RETENTION" envDefault:"720h"`
+	Interval  time.Duration `env:"SOFT_DELETE_PURGE_INTERVAL" envDefault:"1h"`
+}
+
+// Search configures full-text search over posts. Language is a Postgres text
+// search configuration such as english, german or simple.
+type Search struct {
+	Language string `env:"SEARCH_LANGUAGE" envDefault:"english"`
+}
+
+// Publishing controls how often scheduled drafts are checked and published. A
+// zero interval disables scheduled publishing.
+type Publishing struct {
+	Interval time.Duration `env:"POST_PUBLISH_INTERVAL" envDefault:"1m"`
+}
+
 func (d Database) DataSourceName() string {
 	return fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=disable",
 		d.User, d.Password, d.Host, d.Port, d.Name)
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditEventAccountLocked   = "account_locked"
+	AuditEventIPLocked        = "ip_locked"
+	AuditEventUserDisabled    = "user_disabled"
+	AuditEventUserEnabled     = "user_enabled"
+	AuditEventUserDeleted     = "user_deleted"
+	AuditEventUserRestored    = "user_restored"
+	AuditEventSessionsRevoked = "sessions_revoked"
+	AuditEventAccountClaimed  = "account_claimed"
+)
+
+type AuditLog struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    *uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Event     string     `json:"event" gorm:"index"`
+	IP        string     `json:"ip"`
+	Detail    string     `json:"detail"`
+	CreatedAt time.Time
+}
@@ -1,10 +1,15 @@
 package entity
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Category struct {
 	ID        int    `json:"id" gorm:"primaryKey"`
 	Name      string `json:"name"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
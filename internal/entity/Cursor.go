@@ -0,0 +1,12 @@
+package entity
+
+// Cursor is a position in a keyset-paginated list: the sort column value and
+// id of the row a page starts after, or before when Backward is set. Values
+// are kept as text; Postgres casts them back to the column types.
+type Cursor struct {
+	SortBy   string `json:"s"`
+	Desc     bool   `json:"d,omitempty"`
+	Value    string `json:"v"`
+	ID       string `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
@@ -1,13 +1,41 @@
 package entity
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
 type Filter struct {
-	Query      *string
-	Page       *int
-	PerPage    *int
-	SortBy     *string
+	Query   *string
+	Page    *int
+	PerPage *int
+	// SortBy is a column name taken from a whitelist, never raw input.
+	SortBy *string
+	// SortOrder is ASC or DESC.
 	SortOrder  *string
-	StartDate  *string
-	EndDate    *string
-	CategoryID *string
-	UserID     *string
+	StartDate  *time.Time
+	EndDate    *time.Time
+	CategoryID *int
+	AuthorID   *uuid.UUID
+	Status     *string
+	// VisibleTo limits posts to published ones and those written by this
+	// user. Nil shows every post.
+	VisibleTo *uuid.UUID
+	// Limit switches to cursor pagination, reading Limit rows after Cursor
+	// (the first page when Cursor is nil) instead of Page and PerPage.
+	Limit  *int
+	Cursor *Cursor
+	// IncludeDeleted also returns soft-deleted rows. Only admins may set it.
+	IncludeDeleted bool
+}
+
+// DeletedTime returns when a soft-deleted row was deleted, nil if it was not.
+func DeletedTime(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+
+	return &d.Time
 }
@@ -4,16 +4,34 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
 )
 
 type Post struct {
 	ID         int        `json:"id" gorm:"primaryKey"`
 	Title      string     `json:"title"`
 	Content    string     `json:"content"`
-	Slug       string     `json:"slug"`
+	Slug       string     `json:"slug" gorm:"uniqueIndex"`
 	AuthorID   *uuid.UUID `json:"author_id"`
 	CategoryID int        `json:"category_id"`
-	Category   Category   `json:"category" gorm:"foreignKey:CategoryID;references:ID;constraint:OnDelete:CASCADE"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	Status     string     `json:"status"`
+	// PublishedAt is when the post went public, or for a draft when it is
+	// scheduled to.
+	PublishedAt *time.Time `json:"published_at"`
+	Category    Category   `json:"category" gorm:"foreignKey:CategoryID;references:ID;constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	// SearchLanguage is the text search configuration search_vector was
+	// built with, set from config.Search when the post is created.
+	SearchLanguage string `json:"-"`
+	// Headline is the highlighted snippet of a search result. It is only
+	// read, and only filled when listing with a search query.
+	Headline string `json:"-" gorm:"->"`
 }
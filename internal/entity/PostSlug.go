@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// PostSlug is a slug a post had before its title changed. Lookups by an old
+// slug redirect to the current one.
+type PostSlug struct {
+	ID        int    `json:"id" gorm:"primaryKey"`
+	PostID    int    `json:"post_id" gorm:"index"`
+	Slug      string `json:"slug" gorm:"uniqueIndex"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a single-use fallback for a lost TOTP device. Only the
+// hash of the code is stored.
+type RecoveryCode struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
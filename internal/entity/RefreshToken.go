@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken tracks an issued refresh token by its jti. Tokens rotated from
+// the same login share a FamilyID so the whole chain can be revoked at once
+// when an already-rotated token is replayed.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	FamilyID  uuid.UUID  `json:"family_id" gorm:"type:uuid;index"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time
+}
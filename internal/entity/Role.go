@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const (
+	PermissionCategoryWrite = "category:write"
+	PermissionUserManage    = "user:manage"
+)
+
+type Role struct {
+	ID          int          `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"uniqueIndex"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Permission struct {
+	ID        int    `json:"id" gorm:"primaryKey"`
+	Name      string `json:"name" gorm:"uniqueIndex"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
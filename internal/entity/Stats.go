@@ -4,4 +4,7 @@ type Stats struct {
 	Page  int
 	Total int
 	Limit int
+	// Next and Prev point at the neighbouring pages in cursor mode.
+	Next *Cursor
+	Prev *Cursor
 }
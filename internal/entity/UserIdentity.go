@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string    `json:"subject" gorm:"uniqueIndex:idx_user_identities_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
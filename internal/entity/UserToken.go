@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+// UserToken is a single-use token sent to a user by email. Only the hash of
+// the token is stored.
+type UserToken struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
@@ -4,14 +4,23 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	Posts     []Post    `json:"posts" gorm:"foreignKey:AuthorID;references:ID;constraint:OnDelete:SET NULL"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email" gorm:"uniqueIndex:idx_users_email,where:deleted_at IS NULL"`
+	PendingEmail  string     `json:"pending_email,omitempty"`
+	Password      string     `json:"-"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-"`
+	DisabledAt    *time.Time `json:"disabled_at"`
+	Roles         []Role     `json:"roles" gorm:"many2many:user_roles"`
+	Posts         []Post     `json:"posts" gorm:"foreignKey:AuthorID;references:ID;constraint:OnDelete:SET NULL"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
@@ -2,7 +2,120 @@ package apperror
 
 import "errors"
 
+// Kind classifies an Error. resp.WriteError maps each kind to one HTTP
+// status, so services only decide what went wrong, never how to answer.
+type Kind uint8
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindRateLimited
+	// KindUpstream is a failure of a third party the request depended on.
+	KindUpstream
+)
+
+var kindMessages = map[Kind]string{
+	KindInternal:     "internal error",
+	KindNotFound:     "resource not found",
+	KindConflict:     "resource already exists",
+	KindValidation:   "invalid request",
+	KindUnauthorized: "unauthorized",
+	KindForbidden:    "forbidden",
+	KindRateLimited:  "too many requests",
+	KindUpstream:     "upstream service failed",
+}
+
+// Error is a domain error of a given kind with a message that is safe to
+// show to clients. It may wrap the error that caused it.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return kindMessages[e.Kind]
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes the bare kind sentinels (ErrResourceNotFound, ErrConflict, ...)
+// match every error of their kind, so errors.Is(err, ErrResourceNotFound)
+// holds for any not-found error however it was built or wrapped.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Err == nil && t.Kind == e.Kind
+}
+
+// Kind sentinels, matched by kind rather than identity.
 var (
-	ErrResourceNotFound = errors.New("resource not found")
-	ErrInvalidPassword  = errors.New("invalid password")
+	ErrResourceNotFound = &Error{Kind: KindNotFound}
+	ErrConflict         = &Error{Kind: KindConflict}
+	ErrValidation       = &Error{Kind: KindValidation}
+	ErrUnauthorized     = &Error{Kind: KindUnauthorized}
+	ErrForbidden        = &Error{Kind: KindForbidden}
+	ErrRateLimited      = &Error{Kind: KindRateLimited}
 )
+
+var (
+	ErrInvalidPassword  = Unauthorized("invalid password")
+	ErrInvalidLogin     = Unauthorized("invalid email or password")
+	ErrInvalidToken     = Unauthorized("invalid or expired token")
+	ErrEmailNotVerified = Forbidden("email not verified")
+	ErrInvalidMFACode   = Unauthorized("invalid mfa code")
+	ErrMFAAlreadyActive = Conflict("two-factor authentication is already enabled")
+	ErrMFANotEnrolled   = Validation("two-factor authentication is not set up")
+	ErrOAuthFailed      = &Error{Kind: KindUpstream, Message: "could not complete login with the provider"}
+	ErrOAuthNoEmail     = Validation("the provider did not return a verified email")
+)
+
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func Validation(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+func Unauthorized(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func RateLimited(message string) *Error {
+	return &Error{Kind: KindRateLimited, Message: message}
+}
+
+// Wrap returns an error of the given kind and message that still matches err
+// with errors.Is and errors.As.
+func Wrap(err error, kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// KindOf returns the kind of the first Error in err's chain, KindInternal if
+// there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindInternal
+}
@@ -0,0 +1,64 @@
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net-http-boilerplate/internal/entity"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Codec turns cursors into opaque strings for clients and back. The payload
+// is signed so a client cannot forge a position or a sort column.
+type Codec struct {
+	secret []byte
+}
+
+func New(secret string) *Codec {
+	return &Codec{secret: []byte(secret)}
+}
+
+// Encode returns the opaque form of c, an empty string for nil.
+func (c *Codec) Encode(cur *entity.Cursor) string {
+	if cur == nil {
+		return ""
+	}
+
+	payload, err := json.Marshal(cur)
+	if err != nil {
+		return ""
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + c.sign(encoded)
+}
+
+// Decode verifies and parses a string made by Encode.
+func (c *Codec) Decode(s string) (*entity.Cursor, error) {
+	encoded, signature, ok := strings.Cut(s, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cur entity.Cursor
+	if err := json.Unmarshal(payload, &cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cur, nil
+}
+
+func (c *Codec) sign(encoded string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte("cursor." + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,68 @@
+package cursor
+
+import (
+	"errors"
+	"net-http-boilerplate/internal/entity"
+	"strings"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec := New("secret")
+	cur := &entity.Cursor{
+		SortBy:   "created_at",
+		Desc:     true,
+		Value:    "2024-01-02T15:04:05Z",
+		ID:       "42",
+		Backward: true,
+	}
+
+	got, err := codec.Decode(codec.Encode(cur))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *got != *cur {
+		t.Errorf("Decode(Encode(c)) = %+v, want %+v", *got, *cur)
+	}
+}
+
+func TestCodecEncodeNil(t *testing.T) {
+	if got := New("secret").Encode(nil); got != "" {
+		t.Errorf("Encode(nil) = %q, want empty", got)
+	}
+}
+
+func TestCodecDecodeRejects(t *testing.T) {
+	codec := New("secret")
+	valid := codec.Encode(&entity.Cursor{SortBy: "created_at", Value: "2024-01-02T15:04:05Z", ID: "1"})
+	encoded, signature, _ := strings.Cut(valid, ".")
+
+	forged := New("other secret").Encode(&entity.Cursor{SortBy: "created_at", Value: "x", ID: "1"})
+
+	// A payload that was changed but kept the old signature.
+	tampered := New("secret").Encode(&entity.Cursor{SortBy: "password", Value: "x", ID: "1"})
+	tamperedPayload, _, _ := strings.Cut(tampered, ".")
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"no signature", encoded},
+		{"empty signature", encoded + "."},
+		{"wrong signature", encoded + "." + signature[1:]},
+		{"other secret", forged},
+		{"tampered payload", tamperedPayload + "." + signature},
+		{"signed but not base64", "!!!." + codec.sign("!!!")},
+		{"signed but not json", "bm9wZQ." + codec.sign("bm9wZQ")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.Decode(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Decode() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK is the public part of a signing key as described in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every verification key so other services can validate tokens
+// without sharing a secret. With HMAC signing there is nothing to publish and
+// the key list is empty.
+func (j *JWT) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	if j.keys == nil {
+		return set
+	}
+
+	for _, k := range j.keys.sortedKeys() {
+		jwk := JWK{
+			KeyID:     k.id,
+			Use:       "sig",
+			Algorithm: j.keys.method.Alg(),
+		}
+
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encodeSegment(pub.N.Bytes())
+			jwk.E = encodeSegment(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			ecdhKey, err := pub.ECDH()
+			if err != nil {
+				continue
+			}
+
+			// Uncompressed point encoding: 0x04 || X || Y.
+			point := ecdhKey.Bytes()[1:]
+			size := len(point) / 2
+			jwk.KeyType = "EC"
+			jwk.Curve = curveName(pub.Curve)
+			jwk.X = encodeSegment(point[:size])
+			jwk.Y = encodeSegment(point[size:])
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = encodeSegment(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// PublicKey decodes the key, e.g. one published by an identity provider, into
+// an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (k JWK) PublicKey() (any, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: invalid modulus: %w", k.KeyID, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwk %s: invalid exponent", k.KeyID)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Curve {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.KeyID, k.Curve)
+		}
+
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		size := (curve.Params().BitSize + 7) / 8
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("jwk %s: invalid point", k.KeyID)
+		}
+
+		// Rejects points that are not on the curve.
+		if _, err := ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("jwk %s: %w", k.KeyID, err)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: invalid Ed25519 key", k.KeyID)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("jwk %s: unsupported key type %q", k.KeyID, k.KeyType)
+	}
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
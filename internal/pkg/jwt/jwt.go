@@ -1,82 +1,220 @@
 package jwt
 
 import (
+	"errors"
 	"net-http-boilerplate/internal/config"
 	"net-http-boilerplate/internal/pkg/encrypt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
+// TokenUse tells access and refresh tokens apart so one can never be
+// presented in place of the other.
+type TokenUse string
+
+const (
+	TokenUseAccess  TokenUse = "access"
+	TokenUseRefresh TokenUse = "refresh"
+	TokenUseMFA     TokenUse = "mfa"
+)
+
+var ErrWrongTokenUse = errors.New("token is not valid for this use")
+
 type JWT struct {
 	config config.JWT
+	keys   *KeySet
 }
 
 func NewJWT(cfg config.JWT) *JWT {
-	return &JWT{config: cfg}
+	// Without a dedicated refresh secret both kinds are signed with the same
+	// key; the token_use claim still keeps them apart.
+	if cfg.RefreshSecret == "" {
+		cfg.RefreshSecret = cfg.Secret
+	}
+
+	j := &JWT{config: cfg}
+
+	// Any algorithm other than HS256 signs both token kinds with the active
+	// key of the key set instead of the shared secrets.
+	if cfg.Algorithm != "" && cfg.Algorithm != jwt.SigningMethodHS256.Alg() {
+		keys, err := LoadKeySet(cfg.KeysDir, cfg.Algorithm, cfg.ActiveKeyID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load jwt signing keys")
+		}
+
+		j.keys = keys
+	}
+
+	return j
 }
 
 type Claims struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
+	ID       string   `json:"id"`
+	Email    string   `json:"email"`
+	TokenUse TokenUse `json:"token_use"`
+	Roles    []string `json:"roles,omitempty"`
+	// IssuedAtMilli is iat in milliseconds. iat only has second resolution,
+	// too coarse to tell a token issued just before a session revocation
+	// from one issued just after it.
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (j *JWT) GenerateToken(id string, email string) (string, string, error) {
-	encryptedID, err := encrypt.EncryptData(id)
-	if err != nil {
-		return "", "", nil
+// Issued returns when the token was issued and the resolution of that time:
+// a millisecond with iat_ms, a second for tokens issued without it. Without
+// any issue time it is the zero time.
+func (c *Claims) Issued() (time.Time, time.Duration) {
+	if c.IssuedAtMilli > 0 {
+		return time.UnixMilli(c.IssuedAtMilli), time.Millisecond
 	}
 
-	encryptedEmail, err := encrypt.EncryptData(email)
+	if c.IssuedAt == nil {
+		return time.Time{}, time.Second
+	}
+
+	return c.IssuedAt.Time, time.Second
+}
+
+// AccessTokenTTL returns how long an access token stays valid.
+func (j *JWT) AccessTokenTTL() time.Duration {
+	return j.config.AccessTTL
+}
+
+// RefreshTokenTTL returns how long a refresh token stays valid.
+func (j *JWT) RefreshTokenTTL() time.Duration {
+	return j.config.RefreshTTL
+}
+
+func (j *JWT) GenerateToken(id string, email string, roles []string) (string, string, error) {
+	claims, err := j.newClaims(id, email, TokenUseAccess, roles, j.config.AccessTTL)
 	if err != nil {
-		return "", "", nil
+		return "", "", err
 	}
 
-	encryptedIssuer, err := encrypt.EncryptData(j.config.Issuer)
+	accessTokenString, err := j.sign(claims, j.config.Secret)
 	if err != nil {
-		return "", "", nil
+		return "", "", err
 	}
 
-	claims := Claims{
-		ID:    encryptedID,
-		Email: encryptedEmail,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    encryptedIssuer,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	refreshClaims, err := j.newClaims(id, email, TokenUseRefresh, roles, j.config.RefreshTTL)
+	if err != nil {
+		return "", "", err
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	accessTokenString, err := accessToken.SignedString([]byte(j.config.Secret))
+	refreshTokenString, err := j.sign(refreshClaims, j.config.RefreshSecret)
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshClaims := Claims{
-		ID:    encryptedID,
-		Email: encryptedEmail,
+	return accessTokenString, refreshTokenString, nil
+}
+
+// GenerateMFAToken issues the short-lived challenge token returned by a
+// password login when the user still has to pass a second factor. It grants
+// no access by itself and can only be exchanged at the MFA login step.
+func (j *JWT) GenerateMFAToken(id string, email string) (string, error) {
+	claims, err := j.newClaims(id, email, TokenUseMFA, nil, j.config.MFATTL)
+	if err != nil {
+		return "", err
+	}
+
+	return j.sign(claims, j.config.Secret)
+}
+
+// newClaims builds the claims of a token. With HS256 the user id, email and
+// issuer are encrypted with APP_SALT as before. Tokens signed with the key
+// set are meant to be read by other services through the JWKS, so they carry
+// them in plain text, with the user id as sub and JWT_AUDIENCE as aud.
+//
+// Every token carries a unique jti so it can be revoked or rotated
+// individually, even when two are issued in the same second.
+func (j *JWT) newClaims(id string, email string, use TokenUse, roles []string, ttl time.Duration) (Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		ID:            id,
+		Email:         email,
+		TokenUse:      use,
+		Roles:         roles,
+		IssuedAtMilli: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    encryptedIssuer,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			Issuer:    j.config.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(j.config.Secret))
-	if err != nil {
-		return "", "", err
+	if j.config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.config.Audience}
 	}
 
-	return accessTokenString, refreshTokenString, nil
+	if j.keys != nil {
+		claims.Subject = id
+		return claims, nil
+	}
+
+	var err error
+	if claims.ID, err = encrypt.EncryptData(id); err != nil {
+		return Claims{}, err
+	}
+	if claims.Email, err = encrypt.EncryptData(email); err != nil {
+		return Claims{}, err
+	}
+	if claims.Issuer, err = encrypt.EncryptData(j.config.Issuer); err != nil {
+		return Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// ParseAccessToken validates an access token and returns its decrypted claims.
+func (j *JWT) ParseAccessToken(tokenString string) (*Claims, error) {
+	return j.parseToken(tokenString, j.config.Secret, TokenUseAccess)
+}
+
+// ParseRefreshToken validates a refresh token and returns its decrypted claims.
+func (j *JWT) ParseRefreshToken(tokenString string) (*Claims, error) {
+	return j.parseToken(tokenString, j.config.RefreshSecret, TokenUseRefresh)
+}
+
+// ParseMFAToken validates an MFA challenge token and returns its decrypted claims.
+func (j *JWT) ParseMFAToken(tokenString string) (*Claims, error) {
+	return j.parseToken(tokenString, j.config.Secret, TokenUseMFA)
+}
+
+func (j *JWT) sign(claims Claims, secret string) (string, error) {
+	if j.keys != nil {
+		return j.keys.sign(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
 }
 
-func (j *JWT) ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(j.config.Secret), nil
-	})
+func (j *JWT) parseToken(tokenString string, secret string, use TokenUse) (*Claims, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}
+	method := jwt.SigningMethodHS256.Alg()
+
+	if j.keys != nil {
+		keyFunc = j.keys.keyFunc
+		method = j.keys.method.Alg()
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{method}), jwt.WithExpirationRequired()}
+	if j.config.Audience != "" {
+		options = append(options, jwt.WithAudience(j.config.Audience))
+	}
+	// The issuer is only readable in plain-text tokens.
+	if j.keys != nil && j.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(j.config.Issuer))
+	}
+
+	token, err := jwt.Parse(tokenString, keyFunc, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,47 +228,65 @@ func (j *JWT) ParseToken(tokenString string) (*Claims, error) {
 		return nil, jwt.ErrInvalidKeyType
 	}
 
-	// Extract encrypted strings
-	encryptedID := claimsMap["id"].(string)
-	encryptedEmail := claimsMap["email"].(string)
+	if tokenUse, _ := claimsMap["token_use"].(string); TokenUse(tokenUse) != use {
+		return nil, ErrWrongTokenUse
+	}
 
-	// Decrypt
-	decryptedID, err := encrypt.DecryptData(encryptedID)
-	if err != nil {
-		return nil, err
+	id, ok := claimsMap["id"].(string)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	email, ok := claimsMap["email"].(string)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
 	}
 
-	decryptedEmail, err := encrypt.DecryptData(encryptedEmail)
-	if err != nil {
-		return nil, err
+	// Decrypt the claims of HS256 tokens
+	if j.keys == nil {
+		if id, err = encrypt.DecryptData(id); err != nil {
+			return nil, err
+		}
+
+		if email, err = encrypt.DecryptData(email); err != nil {
+			return nil, err
+		}
 	}
 
-	// Convert numeric claims
-	iat := int64(claimsMap["iat"].(float64))
-	exp := int64(claimsMap["exp"].(float64))
+	// Convert numeric claims. Tokens minted elsewhere with the published
+	// keys may leave them out.
+	iat, ok := claimsMap["iat"].(float64)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	exp, ok := claimsMap["exp"].(float64)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	issuedAtMilli, _ := claimsMap["iat_ms"].(float64)
+	jti, _ := claimsMap["jti"].(string)
+	iss, _ := claimsMap["iss"].(string)
+	sub, _ := claimsMap["sub"].(string)
+	var roles []string
+	if rawRoles, ok := claimsMap["roles"].([]interface{}); ok {
+		for _, role := range rawRoles {
+			if name, ok := role.(string); ok {
+				roles = append(roles, name)
+			}
+		}
+	}
 
 	return &Claims{
-		ID:    decryptedID,
-		Email: decryptedEmail,
+		ID:            id,
+		Email:         email,
+		TokenUse:      use,
+		Roles:         roles,
+		IssuedAtMilli: int64(issuedAtMilli),
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    claimsMap["iss"].(string),
-			IssuedAt:  jwt.NewNumericDate(time.Unix(iat, 0)),
-			ExpiresAt: jwt.NewNumericDate(time.Unix(exp, 0)),
+			ID:        jti,
+			Issuer:    iss,
+			Subject:   sub,
+			IssuedAt:  jwt.NewNumericDate(time.Unix(int64(iat), 0)),
+			ExpiresAt: jwt.NewNumericDate(time.Unix(int64(exp), 0)),
 		},
 	}, nil
 }
-
-func (j *JWT) RefreshToken(tokenString string) (string, error) {
-	claims, err := j.ParseToken(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	if claims.ExpiresAt.Time.Before(time.Now()) {
-		return "", jwt.ErrTokenExpired
-	}
-
-	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour * 24))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.config.Secret))
-}
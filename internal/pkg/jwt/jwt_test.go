@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"errors"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/pkg/encrypt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// newTestJWT returns an HS256 JWT whose access and refresh tokens share one
+// secret, so only the token_use claim tells them apart.
+func newTestJWT(t *testing.T) *JWT {
+	t.Helper()
+
+	if err := encrypt.Init("test-salt", "test-salt-iv", "aes-256-cbc"); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewJWT(config.JWT{
+		Secret:     testSecret,
+		Issuer:     "test",
+		AccessTTL:  time.Hour,
+		RefreshTTL: time.Hour,
+		MFATTL:     time.Minute,
+	})
+}
+
+func TestTokenUse(t *testing.T) {
+	j := newTestJWT(t)
+
+	access, refresh, err := j.GenerateToken("user-id", "user@example.com", []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfa, err := j.GenerateMFAToken("user-id", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsers := map[TokenUse]func(string) (*Claims, error){
+		TokenUseAccess:  j.ParseAccessToken,
+		TokenUseRefresh: j.ParseRefreshToken,
+		TokenUseMFA:     j.ParseMFAToken,
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		use   TokenUse
+		err   error
+	}{
+		{"access as access", access, TokenUseAccess, nil},
+		{"refresh as refresh", refresh, TokenUseRefresh, nil},
+		{"mfa as mfa", mfa, TokenUseMFA, nil},
+		{"refresh as access", refresh, TokenUseAccess, ErrWrongTokenUse},
+		{"mfa as access", mfa, TokenUseAccess, ErrWrongTokenUse},
+		{"access as refresh", access, TokenUseRefresh, ErrWrongTokenUse},
+		{"mfa as refresh", mfa, TokenUseRefresh, ErrWrongTokenUse},
+		{"access as mfa", access, TokenUseMFA, ErrWrongTokenUse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parsers[tt.use](tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if claims.ID != "user-id" || claims.Email != "user@example.com" || claims.TokenUse != tt.use {
+				t.Errorf("claims = %+v, want the decrypted user-id, email and %s", claims, tt.use)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsIncompleteClaims(t *testing.T) {
+	j := newTestJWT(t)
+
+	id, err := encrypt.EncryptData("user-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email, err := encrypt.EncryptData("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	complete := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"id":        id,
+			"email":     email,
+			"token_use": string(TokenUseAccess),
+			"jti":       "jti",
+			"iat":       now.Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+		}
+	}
+
+	without := func(claim string) jwt.MapClaims {
+		claims := complete()
+		delete(claims, claim)
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{"complete", complete(), true},
+		{"without iat", without("iat"), false},
+		{"without exp", without("exp"), false},
+		{"without id", without("id"), false},
+		{"without email", without("email"), false},
+		{"without token_use", without("token_use"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte(testSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = j.ParseAccessToken(token)
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("ParseAccessToken() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsOtherSecretsAndExpiry(t *testing.T) {
+	j := newTestJWT(t)
+
+	other := NewJWT(config.JWT{Secret: "other-secret", AccessTTL: time.Hour, RefreshTTL: time.Hour})
+	forged, _, err := other.GenerateToken("user-id", "user@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.ParseAccessToken(forged); err == nil {
+		t.Error("accepted a token signed with another secret")
+	}
+
+	expired := NewJWT(config.JWT{Secret: testSecret, AccessTTL: -time.Minute, RefreshTTL: time.Hour})
+	old, _, err := expired.GenerateToken("user-id", "user@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.ParseAccessToken(old); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("error = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
@@ -0,0 +1,194 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// key is a single entry of a KeySet. Retired keys only have a public half
+// and are kept around to verify tokens that were signed before a rotation.
+type key struct {
+	id      string
+	private any
+	public  any
+}
+
+// KeySet holds the asymmetric keys used to sign and verify tokens. Tokens are
+// always signed with the active key and carry its id in the kid header, while
+// any key in the set is accepted for verification.
+type KeySet struct {
+	method jwt.SigningMethod
+	active *key
+	keys   map[string]*key
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Files may contain a private
+// key (PKCS#1, PKCS#8 or SEC 1) or, for verify-only keys, a PKIX public key.
+func LoadKeySet(dir string, algorithm string, activeKeyID string) (*KeySet, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		method: method,
+		keys:   make(map[string]*key),
+	}
+
+	for _, path := range paths {
+		k, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("load key %s: %w", path, err)
+		}
+
+		if err := checkKeyType(method, k.public); err != nil {
+			return nil, fmt.Errorf("load key %s: %w", path, err)
+		}
+
+		ks.keys[k.id] = k
+	}
+
+	active, ok := ks.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKeyID, dir)
+	}
+
+	if active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKeyID)
+	}
+
+	ks.active = active
+	return ks, nil
+}
+
+func (ks *KeySet) sign(claims Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.private)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return k.public, nil
+}
+
+// sortedKeys returns the keys ordered by id so the JWKS output is stable.
+func (ks *KeySet) sortedKeys() []*key {
+	keys := make([]*key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].id < keys[j].id
+	})
+
+	return keys
+}
+
+func loadKey(path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	k := &key{
+		id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		k.private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		k.private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		k.private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		k.public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		k.public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if k.private != nil {
+		switch priv := k.private.(type) {
+		case *rsa.PrivateKey:
+			k.public = &priv.PublicKey
+		case *ecdsa.PrivateKey:
+			k.public = &priv.PublicKey
+		case ed25519.PrivateKey:
+			k.public = priv.Public()
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", k.private)
+		}
+	}
+
+	return k, nil
+}
+
+func checkKeyType(method jwt.SigningMethod, public any) error {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := public.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case *jwt.SigningMethodECDSA:
+		pub, ok := public.(*ecdsa.PublicKey)
+		if ok && pub.Curve.Params().BitSize == method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil
+		}
+	case *jwt.SigningMethodEd25519:
+		if _, ok := public.(ed25519.PublicKey); ok {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%s is not an asymmetric signing algorithm", method.Alg())
+	}
+
+	return fmt.Errorf("key of type %T cannot be used with %s", public, method.Alg())
+}
+
+// curveName maps a curve to its JWK "crv" name.
+func curveName(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P256():
+		return "P-256"
+	case elliptic.P384():
+		return "P-384"
+	case elliptic.P521():
+		return "P-521"
+	default:
+		return curve.Params().Name
+	}
+}
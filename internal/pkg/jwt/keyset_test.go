@@ -0,0 +1,390 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net-http-boilerplate/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type keyGen func(t *testing.T) crypto.Signer
+
+func rsaKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func ecKey(curve elliptic.Curve) keyGen {
+	return func(t *testing.T) crypto.Signer {
+		t.Helper()
+		k, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+}
+
+func edKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func writePEM(t *testing.T, dir string, kid string, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writePrivateKey stores a signing key as <kid>.pem in PKCS#8 form.
+func writePrivateKey(t *testing.T, dir string, kid string, k crypto.Signer) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+}
+
+// writePublicKey stores the verify-only half of a key as <kid>.pem.
+func writePublicKey(t *testing.T, dir string, kid string, k crypto.Signer) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(k.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, "PUBLIC KEY", der)
+}
+
+func keySetJWT(t *testing.T, algorithm string, dir string, activeKeyID string) *JWT {
+	t.Helper()
+
+	keys, err := LoadKeySet(dir, algorithm, activeKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &JWT{
+		config: config.JWT{
+			Issuer:     "https://auth.example.com",
+			Audience:   "api",
+			AccessTTL:  time.Hour,
+			RefreshTTL: time.Hour,
+		},
+		keys: keys,
+	}
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+var algorithms = []struct {
+	alg string
+	key keyGen
+}{
+	{"RS256", rsaKey},
+	{"PS256", rsaKey},
+	{"ES256", ecKey(elliptic.P256())},
+	{"ES384", ecKey(elliptic.P384())},
+	{"ES512", ecKey(elliptic.P521())},
+	{"EdDSA", edKey},
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	for _, tt := range algorithms {
+		t.Run(tt.alg, func(t *testing.T) {
+			dir := t.TempDir()
+			writePrivateKey(t, dir, "2024-01", tt.key(t))
+			j := keySetJWT(t, tt.alg, dir, "2024-01")
+
+			access, _, err := j.GenerateToken("user-id", "user@example.com", []string{"admin"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if kid := tokenKeyID(t, access); kid != "2024-01" {
+				t.Errorf("kid = %q, want %q", kid, "2024-01")
+			}
+
+			claims, err := j.ParseAccessToken(access)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.ID != "user-id" || claims.Subject != "user-id" || claims.Email != "user@example.com" {
+				t.Errorf("claims = %+v, want plain user-id and email", claims)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := ecKey(elliptic.P256())(t), ecKey(elliptic.P256())(t)
+
+	before := t.TempDir()
+	writePrivateKey(t, before, "old", oldKey)
+	oldToken, _, err := keySetJWT(t, "ES256", before, "old").GenerateToken("user-id", "user@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After the rotation the old key is only kept to verify.
+	rotated := t.TempDir()
+	writePublicKey(t, rotated, "old", oldKey)
+	writePrivateKey(t, rotated, "new", newKey)
+	j := keySetJWT(t, "ES256", rotated, "new")
+
+	newToken, _, err := j.GenerateToken("user-id", "user@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kid := tokenKeyID(t, newToken); kid != "new" {
+		t.Errorf("kid after rotation = %q, want %q", kid, "new")
+	}
+
+	// Once the old key is dropped its tokens stop verifying.
+	retired := t.TempDir()
+	writePrivateKey(t, retired, "new", newKey)
+	withoutOld := keySetJWT(t, "ES256", retired, "new")
+
+	// A token carrying a kid nobody published, signed with a valid key.
+	unknown := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"id": "user-id", "email": "user@example.com", "token_use": "access",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "https://auth.example.com", "aud": "api",
+	})
+	unknown.Header["kid"] = "unknown"
+	unknownToken, err := unknown.SignedString(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		j     *JWT
+		token string
+		valid bool
+	}{
+		{"old token, old key kept", j, oldToken, true},
+		{"new token", j, newToken, true},
+		{"old token, old key dropped", withoutOld, oldToken, false},
+		{"unknown kid", j, unknownToken, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.j.ParseAccessToken(tt.token)
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("ParseAccessToken() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestKeySetRejectsOtherIssuersAndAudiences(t *testing.T) {
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "k1", edKey(t))
+	j := keySetJWT(t, "EdDSA", dir, "k1")
+
+	token, _, err := j.GenerateToken("user-id", "user@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherIssuer := *j
+	otherIssuer.config.Issuer = "https://other.example.com"
+	if _, err := otherIssuer.ParseAccessToken(token); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("error = %v, want %v", err, jwt.ErrTokenInvalidIssuer)
+	}
+
+	otherAudience := *j
+	otherAudience.config.Audience = "other"
+	if _, err := otherAudience.ParseAccessToken(token); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("error = %v, want %v", err, jwt.ErrTokenInvalidAudience)
+	}
+}
+
+func TestLoadKeySetRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		active    string
+		setup     func(t *testing.T, dir string)
+	}{
+		{"RSA key for ES256", "ES256", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", rsaKey(t))
+		}},
+		{"P-384 key for ES256", "ES256", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", ecKey(elliptic.P384())(t))
+		}},
+		{"Ed25519 key for RS256", "RS256", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", edKey(t))
+		}},
+		{"EC key for EdDSA", "EdDSA", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", ecKey(elliptic.P256())(t))
+		}},
+		{"mismatched retired key", "EdDSA", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", edKey(t))
+			writePublicKey(t, dir, "k0", rsaKey(t))
+		}},
+		{"symmetric algorithm", "HS256", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", rsaKey(t))
+		}},
+		{"unknown algorithm", "XX256", "k1", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", edKey(t))
+		}},
+		{"missing active key", "EdDSA", "k2", func(t *testing.T, dir string) {
+			writePrivateKey(t, dir, "k1", edKey(t))
+		}},
+		{"verify-only active key", "EdDSA", "k1", func(t *testing.T, dir string) {
+			writePublicKey(t, dir, "k1", edKey(t))
+		}},
+		{"not a PEM file", "EdDSA", "k1", func(t *testing.T, dir string) {
+			if err := os.WriteFile(filepath.Join(dir, "k1.pem"), []byte("not a key"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"empty directory", "EdDSA", "k1", func(t *testing.T, dir string) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+
+			if _, err := LoadKeySet(dir, tt.algorithm, tt.active); err == nil {
+				t.Error("LoadKeySet() succeeded")
+			}
+		})
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	for _, tt := range algorithms {
+		t.Run(tt.alg, func(t *testing.T) {
+			active, retired := tt.key(t), tt.key(t)
+
+			dir := t.TempDir()
+			writePrivateKey(t, dir, "b-active", active)
+			writePublicKey(t, dir, "a-retired", retired)
+			j := keySetJWT(t, tt.alg, dir, "b-active")
+
+			set := j.JWKS()
+			if len(set.Keys) != 2 || set.Keys[0].KeyID != "a-retired" || set.Keys[1].KeyID != "b-active" {
+				t.Fatalf("JWKS() = %+v, want a-retired and b-active in order", set.Keys)
+			}
+
+			for i, want := range []crypto.Signer{retired, active} {
+				jwk := set.Keys[i]
+				if jwk.Use != "sig" || jwk.Algorithm != tt.alg {
+					t.Errorf("%s: use %q, alg %q, want sig and %s", jwk.KeyID, jwk.Use, jwk.Algorithm, tt.alg)
+				}
+
+				public, err := jwk.PublicKey()
+				if err != nil {
+					t.Fatalf("%s: PublicKey() error = %v", jwk.KeyID, err)
+				}
+
+				if !public.(interface{ Equal(crypto.PublicKey) bool }).Equal(want.Public()) {
+					t.Errorf("%s: PublicKey() does not match the loaded key", jwk.KeyID)
+				}
+			}
+
+			// Another service verifies a token with nothing but the JWKS.
+			token, _, err := j.GenerateToken("user-id", "user@example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+				for _, jwk := range set.Keys {
+					if jwk.KeyID == token.Header["kid"] {
+						return jwk.PublicKey()
+					}
+				}
+				return nil, errors.New("unknown kid")
+			}, jwt.WithValidMethods([]string{tt.alg}))
+			if err != nil {
+				t.Errorf("token does not verify with the JWKS: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKSEmptyForHS256(t *testing.T) {
+	j := NewJWT(config.JWT{Secret: testSecret})
+	if keys := j.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("JWKS().Keys = %v, want an empty list", keys)
+	}
+}
+
+func TestJWKPublicKeyRejects(t *testing.T) {
+	ec := ecKey(elliptic.P256())(t)
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "k1", ec)
+	valid := keySetJWT(t, "ES256", dir, "k1").JWKS().Keys[0]
+
+	offCurve := valid
+	offCurve.Y = valid.X
+
+	wrongCurve := valid
+	wrongCurve.Curve = "P-384"
+
+	badCurve := valid
+	badCurve.Curve = "secp256k1"
+
+	shortEd := JWK{KeyType: "OKP", Curve: "Ed25519", X: encodeSegment([]byte("short"))}
+	otherOKP := JWK{KeyType: "OKP", Curve: "X25519", X: encodeSegment(make([]byte, ed25519.PublicKeySize))}
+
+	tests := []struct {
+		name string
+		jwk  JWK
+	}{
+		{"point not on the curve", offCurve},
+		{"coordinates of another curve", wrongCurve},
+		{"unsupported curve", badCurve},
+		{"short Ed25519 key", shortEd},
+		{"X25519 key", otherOKP},
+		{"RSA without exponent", JWK{KeyType: "RSA", N: encodeSegment([]byte{1, 2, 3})}},
+		{"RSA with a huge exponent", JWK{KeyType: "RSA", N: encodeSegment([]byte{1}), E: encodeSegment(make([]byte, 5))}},
+		{"symmetric key", JWK{KeyType: "oct"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.jwk.PublicKey(); err == nil {
+				t.Error("PublicKey() succeeded")
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package limiter
 
 import (
+	"math"
+	"net-http-boilerplate/internal/api/resp"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -63,7 +66,8 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip := r.RemoteAddr
 		if !rl.Allow(ip) {
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rl.interval.Seconds()))))
+			resp.WriteError(w, resp.NewError(http.StatusTooManyRequests, "too many requests"))
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -0,0 +1,115 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"net-http-boilerplate/internal/config"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"testing"
+	"time"
+)
+
+var testConfig = config.Lockout{
+	Window:      time.Minute,
+	BaseLockout: time.Minute,
+	MaxLockout:  5 * time.Minute,
+}
+
+func TestGuardFail(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore(), testConfig)
+
+	// Threshold 3: two free failures, then the lock doubles up to the cap.
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		got, err := guard.Fail(ctx, "user@example.com", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != w {
+			t.Errorf("failure %d locked for %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestGuardFailWithoutThreshold(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore(), testConfig)
+
+	for range 10 {
+		got, err := guard.Fail(ctx, "user@example.com", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != 0 {
+			t.Fatalf("locked for %s without a threshold", got)
+		}
+	}
+
+	if err := guard.Check(ctx, "user@example.com"); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+}
+
+func TestGuardCheck(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore(), testConfig)
+
+	if err := guard.Check(ctx, "user@example.com", "203.0.113.7"); err != nil {
+		t.Fatalf("Check() before any failure = %v, want nil", err)
+	}
+
+	if _, err := guard.Fail(ctx, "203.0.113.7", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := guard.Check(ctx, "user@example.com", "203.0.113.7")
+	if !errors.Is(err, apperror.ErrRateLimited) {
+		t.Fatalf("Check() = %v, want a rate-limited error", err)
+	}
+
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Check() = %v, want a LockedError", err)
+	}
+
+	if locked.RetryAfter <= 0 || locked.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want up to %s", locked.RetryAfter, time.Minute)
+	}
+
+	// Keys are locked independently.
+	if err := guard.Check(ctx, "user@example.com"); err != nil {
+		t.Errorf("Check() of another key = %v, want nil", err)
+	}
+}
+
+func TestGuardReset(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryStore(), testConfig)
+
+	for range 3 {
+		if _, err := guard.Fail(ctx, "user@example.com", 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := guard.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := guard.Check(ctx, "user@example.com"); err != nil {
+		t.Errorf("Check() after Reset = %v, want nil", err)
+	}
+
+	// The count starts over as well.
+	got, err := guard.Fail(ctx, "user@example.com", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != 0 {
+		t.Errorf("first failure after Reset locked for %s, want 0", got)
+	}
+}
@@ -0,0 +1,79 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type counter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// memoryStore is an in-process Store used when Redis is not configured.
+// Counters are not shared between instances.
+type memoryStore struct {
+	mu       sync.Mutex
+	counters map[string]counter
+	locks    map[string]time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		counters: make(map[string]counter),
+		locks:    make(map[string]time.Time),
+	}
+}
+
+func (s *memoryStore) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	c := s.counters[key]
+	if now.After(c.expiresAt) {
+		c.count = 0
+	}
+
+	c.count++
+	c.expiresAt = now.Add(window)
+	s.counters[key] = c
+
+	return c.count, nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	delete(s.locks, key)
+	return nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locks[key] = time.Now().Add(d)
+	return nil
+}
+
+func (s *memoryStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(s.locks, key)
+		return 0, nil
+	}
+
+	return remaining, nil
+}
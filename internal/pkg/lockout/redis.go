@@ -0,0 +1,49 @@
+package lockout
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, countKey(key))
+	pipe.Expire(ctx, countKey(key), window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(incr.Val()), nil
+}
+
+func (s *redisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, countKey(key), lockKey(key)).Err()
+}
+
+func (s *redisStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	return s.client.Set(ctx, lockKey(key), 1, d).Err()
+}
+
+func (s *redisStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, lockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// PTTL reports -2 for a missing key and -1 for one without expiry.
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
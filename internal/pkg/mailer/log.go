@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// logMailer writes messages to the log instead of sending them, which is
+// enough to follow verification links during local development.
+type logMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	log.Ctx(ctx).Info().
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("email not sent, log mailer in use")
+	return nil
+}
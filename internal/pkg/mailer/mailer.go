@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+	"net-http-boilerplate/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain-text emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER: "smtp" delivers through
+// the configured server, anything else logs the message instead.
+func New(cfg config.Mail) Mailer {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "log", "":
+		return NewLogMailer()
+	default:
+		log.Warn().Msgf("unknown mail driver %q, falling back to log mailer", cfg.Driver)
+		return NewLogMailer()
+	}
+}
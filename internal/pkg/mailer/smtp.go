@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net-http-boilerplate/internal/config"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg config.Mail) Mailer {
+	m := &smtpMailer{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+	}
+
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return m
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String()))
+}
@@ -0,0 +1,277 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net-http-boilerplate/internal/config"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownProvider = errors.New("unknown oauth provider")
+
+// Identity is the account information returned by a provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Token is the token endpoint's answer. IDToken is only set by OpenID
+// Connect providers.
+type Token struct {
+	AccessToken string
+	IDToken     string
+}
+
+// Provider runs the authorization-code flow with PKCE against one identity
+// provider. Every endpoint comes from configuration so the flow can be
+// pointed at a local fake IdP.
+type Provider struct {
+	Name   string
+	config config.OAuthProvider
+	client *http.Client
+	jwks   *keyCache
+}
+
+// defaults fill in the public endpoints of the providers we know about.
+var defaults = map[string]config.OAuthProvider{
+	"google": {
+		AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:    "https://oauth2.googleapis.com/token",
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		Issuer:      "https://accounts.google.com",
+		JWKSURL:     "https://www.googleapis.com/oauth2/v3/certs",
+		Scopes:      []string{"openid", "email", "profile"},
+	},
+	"github": {
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+}
+
+// NewProviders returns every provider that has a client ID configured.
+func NewProviders(cfg config.OAuth) map[string]*Provider {
+	configured := map[string]config.OAuthProvider{
+		"google": cfg.Google,
+		"github": cfg.GitHub,
+	}
+
+	providers := make(map[string]*Provider)
+	for name, c := range configured {
+		if c.ClientID == "" {
+			continue
+		}
+
+		providers[name] = &Provider{
+			Name:   name,
+			config: withDefaults(c, defaults[name]),
+			client: &http.Client{Timeout: 10 * time.Second},
+			jwks:   &keyCache{},
+		}
+	}
+
+	return providers
+}
+
+func withDefaults(c config.OAuthProvider, d config.OAuthProvider) config.OAuthProvider {
+	if c.AuthURL == "" {
+		c.AuthURL = d.AuthURL
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = d.TokenURL
+	}
+	if c.UserInfoURL == "" {
+		c.UserInfoURL = d.UserInfoURL
+	}
+	if c.EmailsURL == "" {
+		c.EmailsURL = d.EmailsURL
+	}
+	if c.Issuer == "" {
+		c.Issuer = d.Issuer
+	}
+	if c.JWKSURL == "" {
+		c.JWKSURL = d.JWKSURL
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = d.Scopes
+	}
+	return c
+}
+
+// NewPKCE returns a code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// AuthCodeURL is where the user is sent to sign in with the provider. OIDC
+// providers echo nonce in the ID token, binding it to this login.
+func (p *Provider) AuthCodeURL(state string, challenge string, nonce string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	if p.OIDC() {
+		q.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(p.config.AuthURL, "?") {
+		sep = "&"
+	}
+
+	return p.config.AuthURL + sep + q.Encode()
+}
+
+// Exchange trades an authorization code for the provider's tokens.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("%s token exchange failed: %s", p.Name, token.Error)
+	}
+
+	return &Token{AccessToken: token.AccessToken, IDToken: token.IDToken}, nil
+}
+
+// FetchIdentity loads the signed-in account from the userinfo endpoint. It
+// understands both OIDC claims (sub, email_verified) and GitHub's user API,
+// where the verified primary email comes from a separate endpoint. For OIDC
+// providers use VerifyIDToken instead.
+func (p *Provider) FetchIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	var info map[string]any
+	if err := p.get(ctx, p.config.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject: stringClaim(info, "sub"),
+		Email:   stringClaim(info, "email"),
+		Name:    stringClaim(info, "name"),
+	}
+
+	if identity.Subject == "" {
+		identity.Subject = stringClaim(info, "id")
+	}
+
+	if identity.Name == "" {
+		identity.Name = stringClaim(info, "login")
+	}
+
+	if verified, ok := info["email_verified"].(bool); ok {
+		identity.EmailVerified = verified
+	}
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%s userinfo has no subject", p.Name)
+	}
+
+	if !identity.EmailVerified && p.config.EmailsURL != "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := p.get(ctx, p.config.EmailsURL, accessToken, &emails); err != nil {
+			return nil, err
+		}
+
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				identity.Email = e.Email
+				identity.EmailVerified = true
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) get(ctx context.Context, endpoint string, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	return p.do(req, out)
+}
+
+func (p *Provider) do(req *http.Request, out any) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s returned %d: %s", p.Name, req.URL.Path, res.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// stringClaim reads a claim that may be encoded as a string or a number,
+// e.g. GitHub's numeric user id.
+func stringClaim(info map[string]any, name string) string {
+	switch v := info[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
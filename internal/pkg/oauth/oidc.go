@@ -0,0 +1,158 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	jwtpkg "net-http-boilerplate/internal/pkg/jwt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksTTL is how long the provider's signing keys are cached.
+	jwksTTL = time.Hour
+	// jwksMinRefresh limits refetching the keys for unknown key ids, so
+	// forged tokens cannot make us hammer the provider.
+	jwksMinRefresh = time.Minute
+)
+
+var errIDTokenNonce = errors.New("id_token nonce does not match")
+
+// idTokenMethods are the algorithms accepted for ID tokens. Symmetric ones are
+// left out on purpose: the client secret must never verify a token.
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// keyCache holds a provider's published signing keys by key id.
+type keyCache struct {
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// OIDC reports whether the provider is an OpenID Connect provider, whose
+// logins are proven by a signed ID token rather than the userinfo response.
+func (p *Provider) OIDC() bool {
+	return p.config.Issuer != "" && p.config.JWKSURL != ""
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token (OIDC Core 3.1.3.7) and returns the identity it asserts.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Identity, error) {
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%s returned no id_token", p.Name)
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	var claims idTokenClaims
+	_, err := parser.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s id_token: %w", p.Name, err)
+	}
+
+	if !p.validIssuer(claims.Issuer) {
+		return nil, fmt.Errorf("%s id_token: unexpected issuer %q", p.Name, claims.Issuer)
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%s: %w", p.Name, errIDTokenNonce)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%s id_token has no subject", p.Name)
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// validIssuer compares iss with the configured issuer. Google may leave the
+// scheme off, which OIDC Core allows for it.
+func (p *Provider) validIssuer(iss string) bool {
+	return iss == p.config.Issuer || (iss != "" && "https://"+iss == p.config.Issuer)
+}
+
+// signingKey returns the provider's key with the given id, refetching the key
+// set when it is stale or the id is new, e.g. after a key rotation.
+func (p *Provider) signingKey(ctx context.Context, kid string) (any, error) {
+	p.jwks.mu.Lock()
+	defer p.jwks.mu.Unlock()
+
+	key, ok := p.jwks.keys[kid]
+	stale := time.Since(p.jwks.fetchedAt) > jwksTTL
+	if ok && !stale {
+		return key, nil
+	}
+
+	if !stale && time.Since(p.jwks.fetchedAt) < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.jwks.keys = keys
+	p.jwks.fetchedAt = time.Now()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var set jwtpkg.JWKS
+	if err := p.do(req, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && !strings.EqualFold(k.Use, "sig") {
+			continue
+		}
+
+		public, err := k.PublicKey()
+		if err != nil {
+			// One key we cannot read must not break the others.
+			continue
+		}
+		keys[k.KeyID] = public
+	}
+
+	return keys, nil
+}
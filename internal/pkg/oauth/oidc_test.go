@@ -0,0 +1,250 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net-http-boilerplate/internal/config"
+	jwtpkg "net-http-boilerplate/internal/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://issuer.example"
+	testClientID = "client-id"
+	testNonce    = "nonce"
+)
+
+// testIDP is an identity provider serving its key set from an httptest
+// server. Keys can be swapped to simulate a rotation.
+type testIDP struct {
+	server  *httptest.Server
+	keys    atomic.Pointer[jwtpkg.JWKS]
+	fetches atomic.Int32
+}
+
+func newTestIDP(t *testing.T, keys ...jwtpkg.JWK) *testIDP {
+	t.Helper()
+
+	idp := &testIDP{}
+	idp.publish(keys...)
+	idp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(idp.keys.Load())
+	}))
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIDP) publish(keys ...jwtpkg.JWK) {
+	idp.keys.Store(&jwtpkg.JWKS{Keys: keys})
+}
+
+func (idp *testIDP) provider() *Provider {
+	return &Provider{
+		Name: "test",
+		config: config.OAuthProvider{
+			ClientID:     testClientID,
+			ClientSecret: "client-secret",
+			Issuer:       testIssuer,
+			JWKSURL:      idp.server.URL,
+		},
+		client: idp.server.Client(),
+		jwks:   &keyCache{},
+	}
+}
+
+func ed25519JWK(t *testing.T, kid string) (ed25519.PrivateKey, jwtpkg.JWK) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return private, jwtpkg.JWK{
+		KeyType:   "OKP",
+		KeyID:     kid,
+		Use:       "sig",
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(public),
+	}
+}
+
+func ecdsaJWK(t *testing.T, kid string) (*ecdsa.PrivateKey, jwtpkg.JWK) {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return private, jwtpkg.JWK{
+		KeyType:   "EC",
+		KeyID:     kid,
+		Algorithm: "ES256",
+		Curve:     "P-256",
+		X:         base64.RawURLEncoding.EncodeToString(private.X.FillBytes(make([]byte, 32))),
+		Y:         base64.RawURLEncoding.EncodeToString(private.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            testIssuer,
+		"aud":            testClientID,
+		"sub":            "subject",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "User",
+		"nonce":          testNonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	edKey, edJWK := ed25519JWK(t, "ed")
+	ecKey, ecJWK := ecdsaJWK(t, "ec")
+	otherKey, _ := ed25519JWK(t, "ed")
+	idp := newTestIDP(t, edJWK, ecJWK)
+
+	with := func(name string, value any) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		valid bool
+	}{
+		{"EdDSA", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, validClaims()), testNonce, true},
+		{"ES256", sign(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()), testNonce, true},
+		{"issuer without scheme", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("iss", "issuer.example")), testNonce, true},
+		{"audience among several", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("aud", []string{"other", testClientID})), testNonce, true},
+		{"wrong issuer", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("iss", "https://evil.example")), testNonce, false},
+		{"no issuer", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("iss", nil)), testNonce, false},
+		{"wrong audience", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("aud", "other-client")), testNonce, false},
+		{"no audience", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("aud", nil)), testNonce, false},
+		{"nonce mismatch", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, validClaims()), "other-nonce", false},
+		{"no expected nonce", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("nonce", "")), "", false},
+		{"expired", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("exp", time.Now().Add(-time.Minute).Unix())), testNonce, false},
+		{"no expiry", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("exp", nil)), testNonce, false},
+		{"issued in the future", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("iat", time.Now().Add(time.Hour).Unix())), testNonce, false},
+		{"no subject", sign(t, jwt.SigningMethodEdDSA, "ed", edKey, with("sub", nil)), testNonce, false},
+		{"unknown kid", sign(t, jwt.SigningMethodEdDSA, "missing", edKey, validClaims()), testNonce, false},
+		{"no kid", sign(t, jwt.SigningMethodEdDSA, "", edKey, validClaims()), testNonce, false},
+		{"other key under a known kid", sign(t, jwt.SigningMethodEdDSA, "ed", otherKey, validClaims()), testNonce, false},
+		{"algorithm of another key", sign(t, jwt.SigningMethodES256, "ed", ecKey, validClaims()), testNonce, false},
+		{"client secret", sign(t, jwt.SigningMethodHS256, "ed", []byte("client-secret"), validClaims()), testNonce, false},
+		{"empty", "", testNonce, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := idp.provider().VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if valid := err == nil; valid != tt.valid {
+				t.Fatalf("VerifyIDToken() error = %v, want valid %v", err, tt.valid)
+			}
+
+			if err != nil {
+				return
+			}
+
+			want := Identity{Subject: "subject", Email: "user@example.com", EmailVerified: true, Name: "User"}
+			if *identity != want {
+				t.Errorf("identity = %+v, want %+v", *identity, want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	oldKey, oldJWK := ed25519JWK(t, "old")
+	newKey, newJWK := ed25519JWK(t, "new")
+	idp := newTestIDP(t, oldJWK)
+	p := idp.provider()
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, sign(t, jwt.SigningMethodEdDSA, "old", oldKey, validClaims()), testNonce); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider rotates. Until jwksMinRefresh has passed an unknown kid
+	// is rejected without asking the provider again.
+	idp.publish(newJWK)
+	rotated := sign(t, jwt.SigningMethodEdDSA, "new", newKey, validClaims())
+
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); err == nil {
+		t.Error("VerifyIDToken() refetched the keys right after a fetch")
+	}
+	if n := idp.fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+
+	p.jwks.fetchedAt = time.Now().Add(-jwksMinRefresh - time.Second)
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); err != nil {
+		t.Errorf("VerifyIDToken() after the rotation = %v", err)
+	}
+	if n := idp.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+
+	if _, err := p.VerifyIDToken(ctx, sign(t, jwt.SigningMethodEdDSA, "old", oldKey, validClaims()), testNonce); err == nil {
+		t.Error("VerifyIDToken() accepted a key the provider no longer publishes")
+	}
+}
+
+func TestFetchKeysSkipsUnusableKeys(t *testing.T) {
+	_, good := ed25519JWK(t, "good")
+	_, encryption := ed25519JWK(t, "enc")
+	encryption.Use = "enc"
+	broken := jwtpkg.JWK{KeyType: "OKP", KeyID: "broken", Curve: "Ed25519", X: "short"}
+
+	idp := newTestIDP(t, good, encryption, broken)
+
+	keys, err := idp.provider().fetchKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || keys["good"] == nil {
+		t.Errorf("keys = %v, want only good", keys)
+	}
+}
@@ -20,6 +20,9 @@ const (
 func NewGORM(c *config.Database) *gorm.DB {
 	db, err := gorm.Open(postgres.Open(c.DataSourceName()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
+		// Report constraint violations as gorm.ErrDuplicatedKey and
+		// gorm.ErrForeignKeyViolated so services can map them to 409/400.
+		TranslateError: true,
 	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to database")
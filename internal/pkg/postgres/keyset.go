@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"fmt"
+	"net-http-boilerplate/internal/entity"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KeysetPage reads one page of query ordered by column, then by primary key,
+// starting after cursor, or before it when the cursor points backwards. No
+// OFFSET or COUNT is involved, so pages stay fast and stable while rows are
+// inserted. One extra row is read to learn whether another page follows; the
+// returned stats hold the cursors of the neighbouring pages, nil at either
+// end. column must come from a whitelist.
+func KeysetPage[T any](query *gorm.DB, column string, desc bool, cursor *entity.Cursor, limit int) ([]T, *entity.Stats, error) {
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, nil, err
+	}
+
+	sortField := stmt.Schema.LookUpField(column)
+	idField := stmt.Schema.PrioritizedPrimaryField
+	if sortField == nil || idField == nil {
+		return nil, nil, fmt.Errorf("cannot paginate %s by %q", stmt.Schema.Table, column)
+	}
+
+	sortColumn := clause.Column{Table: clause.CurrentTable, Name: sortField.DBName}
+	idColumn := clause.Column{Table: clause.CurrentTable, Name: idField.DBName}
+
+	// Walking backwards reads the rows before the cursor in reverse order.
+	backward := cursor != nil && cursor.Backward
+	reverse := desc != backward
+
+	if cursor != nil {
+		op := ">"
+		if reverse {
+			op = "<"
+		}
+		query = query.Where(clause.Expr{
+			SQL:  "(?, ?) " + op + " (?, ?)",
+			Vars: []any{sortColumn, idColumn, cursor.Value, cursor.ID},
+		})
+	}
+
+	var rows []T
+	err := query.
+		Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: sortColumn, Desc: reverse},
+			{Column: idColumn, Desc: reverse},
+		}}).
+		Limit(limit + 1).
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	stats := &entity.Stats{Limit: limit}
+	if len(rows) == 0 {
+		return rows, stats, nil
+	}
+
+	position := func(row *T, backward bool) *entity.Cursor {
+		value := reflect.ValueOf(row).Elem()
+		sortValue, _ := sortField.ValueOf(query.Statement.Context, value)
+		idValue, _ := idField.ValueOf(query.Statement.Context, value)
+		return &entity.Cursor{
+			SortBy:   column,
+			Desc:     desc,
+			Value:    cursorValue(sortValue),
+			ID:       cursorValue(idValue),
+			Backward: backward,
+		}
+	}
+
+	// A page reached by a forward cursor always has rows before it, and one
+	// reached by a backward cursor rows after it.
+	if (backward && more) || (!backward && cursor != nil) {
+		stats.Prev = position(&rows[0], true)
+	}
+	if (!backward && more) || backward {
+		stats.Next = position(&rows[len(rows)-1], false)
+	}
+
+	return rows, stats, nil
+}
+
+func cursorValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(v)
+}
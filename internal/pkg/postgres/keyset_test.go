@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net-http-boilerplate/internal/entity"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type item struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+}
+
+// fakeDriver answers every query with the rows of the current test and
+// records the SQL and arguments it was sent.
+type fakeDriver struct {
+	mu    sync.Mutex
+	rows  [][]driver.Value
+	query string
+	args  []driver.Value
+}
+
+var itemColumns = []string{"id", "name", "created_at"}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.query = query
+	d.args = nil
+	for _, arg := range args {
+		d.args = append(d.args, arg.Value)
+	}
+
+	return &fakeRows{rows: d.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return itemColumns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var registerOnce sync.Once
+var testDriver = &fakeDriver{}
+
+func newTestDB(t *testing.T, rows ...item) *gorm.DB {
+	t.Helper()
+
+	registerOnce.Do(func() { sql.Register("keyset-test", testDriver) })
+
+	testDriver.mu.Lock()
+	testDriver.rows = nil
+	for _, row := range rows {
+		testDriver.rows = append(testDriver.rows, []driver.Value{int64(row.ID), row.Name, row.CreatedAt})
+	}
+	testDriver.mu.Unlock()
+
+	sqlDB, err := sql.Open("keyset-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Default.LogMode(logger.Silent),
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func items(n int) []item {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	res := make([]item, n)
+	for i := range res {
+		res[i] = item{ID: i + 1, Name: "item", CreatedAt: start.Add(time.Duration(i) * time.Hour)}
+	}
+	return res
+}
+
+func ids(rows []item) []int {
+	res := make([]int, len(rows))
+	for i, row := range rows {
+		res[i] = row.ID
+	}
+	return res
+}
+
+func TestKeysetPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		desc   bool
+		cursor *entity.Cursor
+		// rows is what the database returns for the query.
+		rows     []item
+		wantSQL  string
+		wantArgs []driver.Value
+		wantIDs  []int
+		wantPrev *entity.Cursor
+		wantNext *entity.Cursor
+	}{
+		{
+			name:     "first page with more",
+			rows:     items(3),
+			wantSQL:  `SELECT * FROM "items" ORDER BY "items"."created_at","items"."id" LIMIT $1`,
+			wantArgs: []driver.Value{int64(3)},
+			wantIDs:  []int{1, 2},
+			wantNext: &entity.Cursor{SortBy: "created_at", Value: "2024-01-01T01:00:00Z", ID: "2"},
+		},
+		{
+			name:     "only page",
+			rows:     items(2),
+			wantSQL:  `SELECT * FROM "items" ORDER BY "items"."created_at","items"."id" LIMIT $1`,
+			wantArgs: []driver.Value{int64(3)},
+			wantIDs:  []int{1, 2},
+		},
+		{
+			name:     "last page descending",
+			desc:     true,
+			cursor:   &entity.Cursor{SortBy: "created_at", Desc: true, Value: "2024-01-01T05:00:00Z", ID: "6"},
+			rows:     items(2),
+			wantSQL:  `SELECT * FROM "items" WHERE ("items"."created_at", "items"."id") < ($1, $2) ORDER BY "items"."created_at" DESC,"items"."id" DESC LIMIT $3`,
+			wantArgs: []driver.Value{"2024-01-01T05:00:00Z", "6", int64(3)},
+			wantIDs:  []int{1, 2},
+			wantPrev: &entity.Cursor{SortBy: "created_at", Desc: true, Value: "2024-01-01T00:00:00Z", ID: "1", Backward: true},
+		},
+		{
+			name:   "backward page with more",
+			cursor: &entity.Cursor{SortBy: "created_at", Value: "2024-01-01T05:00:00Z", ID: "6", Backward: true},
+			// Read in reverse, nearest to the cursor first.
+			rows:     []item{items(5)[4], items(5)[3], items(5)[2]},
+			wantSQL:  `SELECT * FROM "items" WHERE ("items"."created_at", "items"."id") < ($1, $2) ORDER BY "items"."created_at" DESC,"items"."id" DESC LIMIT $3`,
+			wantArgs: []driver.Value{"2024-01-01T05:00:00Z", "6", int64(3)},
+			wantIDs:  []int{4, 5},
+			wantPrev: &entity.Cursor{SortBy: "created_at", Value: "2024-01-01T03:00:00Z", ID: "4", Backward: true},
+			wantNext: &entity.Cursor{SortBy: "created_at", Value: "2024-01-01T04:00:00Z", ID: "5"},
+		},
+		{
+			name:     "empty page",
+			cursor:   &entity.Cursor{SortBy: "created_at", Value: "2024-01-01T05:00:00Z", ID: "6"},
+			wantSQL:  `SELECT * FROM "items" WHERE ("items"."created_at", "items"."id") > ($1, $2) ORDER BY "items"."created_at","items"."id" LIMIT $3`,
+			wantArgs: []driver.Value{"2024-01-01T05:00:00Z", "6", int64(3)},
+			wantIDs:  []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, tt.rows...)
+
+			rows, stats, err := KeysetPage[item](db.Model(&item{}), "created_at", tt.desc, tt.cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.TrimSpace(testDriver.query); got != tt.wantSQL {
+				t.Errorf("SQL = %s\nwant  %s", got, tt.wantSQL)
+			}
+
+			if !reflect.DeepEqual(testDriver.args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", testDriver.args, tt.wantArgs)
+			}
+
+			if got := ids(rows); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", got, tt.wantIDs)
+			}
+
+			if !reflect.DeepEqual(stats.Prev, tt.wantPrev) {
+				t.Errorf("prev = %+v, want %+v", stats.Prev, tt.wantPrev)
+			}
+
+			if !reflect.DeepEqual(stats.Next, tt.wantNext) {
+				t.Errorf("next = %+v, want %+v", stats.Next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestKeysetPageUnknownColumn(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, _, err := KeysetPage[item](db.Model(&item{}), "password", false, nil, 2); err == nil {
+		t.Error("KeysetPage() by an unknown column succeeded")
+	}
+}
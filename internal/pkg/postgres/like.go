@@ -0,0 +1,14 @@
+package postgres
+
+import "strings"
+
+// likeEscaper escapes the characters LIKE treats specially, the escape
+// character itself first.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsPattern returns a LIKE pattern matching values that contain s
+// literally. Use it with ESCAPE '\', e.g. "name ILIKE ? ESCAPE '\'", so a
+// search for "50%" does not match everything that starts with "50".
+func ContainsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
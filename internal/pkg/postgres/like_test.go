@@ -0,0 +1,25 @@
+package postgres
+
+import "testing"
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", `%%`},
+		{"go", `%go%`},
+		{"50%", `%50\%%`},
+		{"snake_case", `%snake\_case%`},
+		{`C:\temp`, `%C:\\temp%`},
+		{`\%`, `%\\\%%`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := ContainsPattern(tt.in); got != tt.want {
+				t.Errorf("ContainsPattern(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"net-http-boilerplate/internal/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListOptions adapt List to a table.
+type ListOptions struct {
+	// Rows is applied to the query that reads the rows but not to the count,
+	// e.g. to select computed columns or preload associations.
+	Rows func(query *gorm.DB) *gorm.DB
+	// OrderFirst orders page mode before the filter's sort column, e.g. by
+	// search relevance. Cursor pagination always orders by the cursor's key.
+	OrderFirst []clause.OrderByColumn
+}
+
+// List runs the parts of a list query that every table shares on query,
+// which already holds the table's own conditions: deleted rows, created_at
+// bounds, the sort order and either cursor or page pagination. Without
+// pagination every row is returned and the stats are nil.
+func List[T any](query *gorm.DB, filter *entity.Filter, opts ListOptions) ([]T, *entity.Stats, error) {
+	var res []T
+	var total int64
+
+	rows := func(query *gorm.DB) *gorm.DB {
+		if opts.Rows == nil {
+			return query
+		}
+		return opts.Rows(query)
+	}
+
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
+
+	if filter.StartDate != nil {
+		query = query.Where("created_at >= ?", *filter.StartDate)
+	}
+
+	if filter.EndDate != nil {
+		query = query.Where("created_at <= ?", *filter.EndDate)
+	}
+
+	// SortBy is whitelisted by query.ParseFilter; quoting it as a column
+	// keeps anything else out of ORDER BY regardless.
+	sortBy := "created_at"
+	if filter.SortBy != nil {
+		sortBy = *filter.SortBy
+	}
+	desc := filter.SortOrder != nil && *filter.SortOrder == "DESC"
+
+	// Cursor pagination needs no count and orders by the cursor's key.
+	if filter.Limit != nil {
+		return KeysetPage[T](rows(query), sortBy, desc, filter.Cursor, *filter.Limit)
+	}
+
+	if filter.Page != nil && filter.PerPage != nil {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	query = rows(query)
+	for _, column := range opts.OrderFirst {
+		query = query.Order(column)
+	}
+	query = query.Order(clause.OrderByColumn{
+		Column: clause.Column{Name: sortBy},
+		Desc:   desc,
+	})
+
+	if filter.Page == nil || filter.PerPage == nil {
+		if err := query.Find(&res).Error; err != nil {
+			return nil, nil, err
+		}
+		return res, nil, nil
+	}
+
+	page := *filter.Page
+	perPage := *filter.PerPage
+	if err := query.Limit(perPage).Offset((page - 1) * perPage).Find(&res).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return res, &entity.Stats{
+		Page:  page,
+		Total: int(total),
+		Limit: perPage,
+	}, nil
+}
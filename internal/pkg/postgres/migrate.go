@@ -1,21 +1,425 @@
 package postgres
 
 import (
-	"net-http-boilerplate/internal/entity"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_lock key held while migrating, so
+// instances starting together apply each migration exactly once.
+const migrationLockID int64 = 4_281_772_519
+
+// Migration is a pair of embedded SQL files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration and when it was applied, nil if it is
+// pending. Missing marks an applied version that has no file any more.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+// Migrator applies the embedded migrations and records them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	dryRun     io.Writer
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         sqlDB,
+		migrations: migrations,
+	}, nil
+}
+
+// Migrate brings the schema up to date on startup.
 func Migrate(db *gorm.DB) {
-	err := db.AutoMigrate(
-		&entity.HealthCheck{},
-		&entity.User{},
-		&entity.Category{},
-	)
+	migrator, err := NewMigrator(db)
 	if err != nil {
-		log.Fatal().Err(err).Msgf("failed to auto migrate, err: %v", err.Error())
+		log.Fatal().Err(err).Msgf("failed to load migrations, err: %v", err.Error())
+	}
+
+	if err := migrator.Up(context.Background()); err != nil {
+		log.Fatal().Err(err).Msgf("failed to migrate, err: %v", err.Error())
 	}
 
 	log.Info().Msg("migration completed")
 }
+
+// DryRun makes the migrator write the SQL it would run to out instead of
+// running it. Nothing is recorded in schema_migrations.
+func (m *Migrator) DryRun(out io.Writer) {
+	m.dryRun = out
+}
+
+// Up applies every migration that has not been applied yet, oldest first.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			n--
+		}
+
+		return nil
+	})
+}
+
+// Goto migrates up or down until exactly the migrations up to and including
+// version are applied. Version 0 reverts everything.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status lists every known or applied migration ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var res []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if record, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &record.AppliedAt
+				delete(applied, migration.Version)
+			}
+			res = append(res, status)
+		}
+
+		for version, record := range applied {
+			res = append(res, MigrationStatus{
+				Migration: Migration{Version: version, Name: record.Name},
+				AppliedAt: &record.AppliedAt,
+				Missing:   true,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+
+	return res, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+
+	return nil
+}
+
+// apply runs one direction of a migration and records it in the same
+// transaction, so a failed migration leaves no trace.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	script, record, direction := migration.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", "up"
+	if !up {
+		script, record, direction = migration.Down, "DELETE FROM schema_migrations WHERE version = $1 AND name = $2", "down"
+	}
+
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %d_%s.%s.sql\n%s\n", migration.Version, migration.Name, direction, script)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, record, migration.Version, migration.Name); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Info().Int64("version", migration.Version).Str("name", migration.Name).Str("direction", direction).Msg("applied migration")
+	return nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock. Session locks belong to a connection, hence the dedicated one. A dry
+// run leaves the schema untouched and does not create schema_migrations.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			log.Error().Err(err).Msg("failed to release migration lock")
+		}
+	}()
+
+	if m.dryRun != nil {
+		return fn(conn)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+type appliedMigration struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+// appliedVersions returns the recorded migrations by version. A database that
+// was never migrated, which a dry run may see, has none.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.Name, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+
+	return applied, rows.Err()
+}
+
+var migrationNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// NewMigrationFiles returns the up and down file paths for a new migration
+// in dir, numbered after the highest version already there. The name is
+// lower-cased with runs of other characters replaced by underscores.
+func NewMigrationFiles(dir string, name string) (string, string, error) {
+	name = strings.Trim(migrationNameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name must contain a letter or digit")
+	}
+
+	migrations, err := loadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	stem := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	return stem + ".up.sql", stem + ".down.sql", nil
+}
+
+// loadMigrations reads the migrations in fsys sorted by version. Every
+// version must have exactly one up and one down file.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	type pair struct {
+		Migration
+		hasUp, hasDown bool
+	}
+
+	byVersion := make(map[int64]*pair)
+	for _, file := range files {
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must end in .up.sql or .down.sql", file)
+		}
+
+		prefix, name, ok := strings.Cut(stem, "_")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("migration %s: name must start with <version>_<name>", file)
+		}
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &pair{Migration: Migration{Version: version, Name: name}}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", file, version, migration.Name)
+		}
+
+		if direction == "up" {
+			migration.Up, migration.hasUp = string(content), true
+		} else {
+			migration.Down, migration.hasDown = string(content), true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !migration.hasUp || !migration.hasDown {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration.Migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,423 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// migrateDB is the state behind one fake migration database: the versions
+// recorded in schema_migrations and every migration script run, in order.
+type migrateDB struct {
+	mu      sync.Mutex
+	tracked bool
+	applied map[int64]string
+	scripts []string
+	fail    string
+}
+
+// migrateDriver hands out connections to the migrateDB registered under the
+// data source name, so tests running one after another do not share state.
+type migrateDriver struct {
+	dbs sync.Map
+}
+
+func (d *migrateDriver) Open(name string) (driver.Conn, error) {
+	db, ok := d.dbs.Load(name)
+	if !ok {
+		return nil, errors.New("unknown test database " + name)
+	}
+	return &migrateConn{db: db.(*migrateDB)}, nil
+}
+
+type migrateConn struct {
+	db *migrateDB
+	tx *migrateTx
+}
+
+func (c *migrateConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *migrateConn) Close() error                              { return nil }
+func (c *migrateConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *migrateConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.tx = &migrateTx{conn: c}
+	return c.tx, nil
+}
+
+func (c *migrateConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "pg_advisory"):
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		db.tracked = true
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version, name := args[0].Value.(int64), args[1].Value.(string)
+		c.tx.ops = append(c.tx.ops, func() { db.applied[version] = name })
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		version := args[0].Value.(int64)
+		c.tx.ops = append(c.tx.ops, func() { delete(db.applied, version) })
+	default:
+		if db.fail != "" && query == db.fail {
+			return nil, errors.New("syntax error")
+		}
+		c.tx.ops = append(c.tx.ops, func() { db.scripts = append(db.scripts, query) })
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *migrateConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if strings.Contains(query, "to_regclass") {
+		return &migrateRows{columns: []string{"exists"}, rows: [][]driver.Value{{db.tracked}}}, nil
+	}
+
+	rows := &migrateRows{columns: []string{"version", "name", "applied_at"}}
+	for version, name := range db.applied {
+		rows.rows = append(rows.rows, []driver.Value{version, name, time.Now()})
+	}
+	return rows, nil
+}
+
+// migrateTx holds back the statements of a transaction until it commits.
+type migrateTx struct {
+	conn *migrateConn
+	ops  []func()
+}
+
+func (tx *migrateTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	defer tx.conn.db.mu.Unlock()
+
+	for _, op := range tx.ops {
+		op()
+	}
+	tx.ops = nil
+	return nil
+}
+
+func (tx *migrateTx) Rollback() error {
+	tx.ops = nil
+	return nil
+}
+
+type migrateRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *migrateRows) Columns() []string { return r.columns }
+func (r *migrateRows) Close() error      { return nil }
+
+func (r *migrateRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var registerMigrateOnce sync.Once
+var testMigrateDriver = &migrateDriver{}
+
+// testMigrations are three migrations whose scripts name themselves, listed
+// out of order to check they are sorted by version.
+var testMigrations = fstest.MapFS{
+	"0003_third.up.sql":    {Data: []byte("up 3")},
+	"0003_third.down.sql":  {Data: []byte("down 3")},
+	"0001_first.up.sql":    {Data: []byte("up 1")},
+	"0001_first.down.sql":  {Data: []byte("down 1")},
+	"0002_second.up.sql":   {Data: []byte("up 2")},
+	"0002_second.down.sql": {Data: []byte("down 2")},
+}
+
+// newTestMigrator returns a migrator over testMigrations whose database
+// already has the applied versions recorded.
+func newTestMigrator(t *testing.T, applied ...int64) (*Migrator, *migrateDB) {
+	t.Helper()
+
+	registerMigrateOnce.Do(func() { sql.Register("migrate-test", testMigrateDriver) })
+
+	migrations, err := loadMigrations(testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &migrateDB{tracked: len(applied) > 0, applied: make(map[int64]string)}
+	for _, version := range applied {
+		for _, migration := range migrations {
+			if migration.Version == version {
+				db.applied[version] = migration.Name
+			}
+		}
+	}
+	testMigrateDriver.dbs.Store(t.Name(), db)
+
+	sqlDB, err := sql.Open("migrate-test", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return &Migrator{db: sqlDB, migrations: migrations}, db
+}
+
+func versions(db *migrateDB) []int64 {
+	res := []int64{}
+	for version := int64(1); version <= 3; version++ {
+		if _, ok := db.applied[version]; ok {
+			res = append(res, version)
+		}
+	}
+	return res
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+		{Version: 3, Name: "third", Up: "up 3", Down: "down 3"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("loadMigrations() = %+v, want %+v", migrations, want)
+	}
+}
+
+func TestLoadMigrationsRejects(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1")}
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{"0001_init.up.sql": file}},
+		{"missing up", fstest.MapFS{"0001_init.down.sql": file}},
+		{"unknown direction", fstest.MapFS{"0001_init.sideways.sql": file}},
+		{"no direction", fstest.MapFS{"0001_init.sql": file}},
+		{"no name", fstest.MapFS{"0001.up.sql": file, "0001.down.sql": file}},
+		{"invalid version", fstest.MapFS{"first_init.up.sql": file, "first_init.down.sql": file}},
+		{"version used twice", fstest.MapFS{
+			"0001_init.up.sql":    file,
+			"0001_init.down.sql":  file,
+			"0001_other.up.sql":   file,
+			"0001_other.down.sql": file,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files); err == nil {
+				t.Error("loadMigrations() succeeded")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s, want version %d", migration.Version, migration.Name, i+1)
+		}
+	}
+}
+
+func TestMigratorOrdering(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []int64
+		run     func(context.Context, *Migrator) error
+		scripts []string
+		want    []int64
+	}{
+		{
+			name:    "up from scratch",
+			run:     func(ctx context.Context, m *Migrator) error { return m.Up(ctx) },
+			scripts: []string{"up 1", "up 2", "up 3"},
+			want:    []int64{1, 2, 3},
+		},
+		{
+			name:    "up applies only pending",
+			applied: []int64{1},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Up(ctx) },
+			scripts: []string{"up 2", "up 3"},
+			want:    []int64{1, 2, 3},
+		},
+		{
+			name:    "up fills a gap",
+			applied: []int64{1, 3},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Up(ctx) },
+			scripts: []string{"up 2"},
+			want:    []int64{1, 2, 3},
+		},
+		{
+			name:    "down reverts newest first",
+			applied: []int64{1, 2, 3},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Down(ctx, 2) },
+			scripts: []string{"down 3", "down 2"},
+			want:    []int64{1},
+		},
+		{
+			name:    "down skips unapplied",
+			applied: []int64{1, 2},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Down(ctx, 1) },
+			scripts: []string{"down 2"},
+			want:    []int64{1},
+		},
+		{
+			name:    "down past the first",
+			applied: []int64{1},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Down(ctx, 5) },
+			scripts: []string{"down 1"},
+			want:    []int64{},
+		},
+		{
+			name:    "goto up",
+			applied: []int64{1},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Goto(ctx, 2) },
+			scripts: []string{"up 2"},
+			want:    []int64{1, 2},
+		},
+		{
+			name:    "goto down",
+			applied: []int64{1, 2, 3},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Goto(ctx, 1) },
+			scripts: []string{"down 3", "down 2"},
+			want:    []int64{1},
+		},
+		{
+			name:    "goto reverts above and applies below",
+			applied: []int64{1, 3},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Goto(ctx, 2) },
+			scripts: []string{"down 3", "up 2"},
+			want:    []int64{1, 2},
+		},
+		{
+			name:    "goto zero",
+			applied: []int64{1, 2, 3},
+			run:     func(ctx context.Context, m *Migrator) error { return m.Goto(ctx, 0) },
+			scripts: []string{"down 3", "down 2", "down 1"},
+			want:    []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMigrator(t, tt.applied...)
+
+			if err := tt.run(context.Background(), m); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(db.scripts, tt.scripts) {
+				t.Errorf("scripts = %q, want %q", db.scripts, tt.scripts)
+			}
+
+			if got := versions(db); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigratorGotoUnknownVersion(t *testing.T) {
+	m, db := newTestMigrator(t, 1)
+
+	if err := m.Goto(context.Background(), 7); err == nil {
+		t.Error("Goto() an unknown version succeeded")
+	}
+
+	if len(db.scripts) != 0 {
+		t.Errorf("scripts = %q, want none", db.scripts)
+	}
+}
+
+func TestMigratorStopsAtFailure(t *testing.T) {
+	m, db := newTestMigrator(t)
+	db.fail = "up 2"
+
+	if err := m.Up(context.Background()); err == nil {
+		t.Fatal("Up() succeeded past a failing migration")
+	}
+
+	if got := versions(db); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("applied = %v, want [1]", got)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	m, db := newTestMigrator(t, 1)
+
+	var out bytes.Buffer
+	m.DryRun(&out)
+
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "-- 2_second.up.sql\nup 2\n-- 3_third.up.sql\nup 3\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	if len(db.scripts) != 0 {
+		t.Errorf("scripts = %q, want none run", db.scripts)
+	}
+
+	if got := versions(db); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("applied = %v, want [1]", got)
+	}
+}
+
+func TestMigratorDryRunUntracked(t *testing.T) {
+	m, db := newTestMigrator(t)
+
+	var out bytes.Buffer
+	m.DryRun(&out)
+
+	if err := m.Down(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing to revert", out.String())
+	}
+
+	if db.tracked {
+		t.Error("dry run created schema_migrations")
+	}
+}
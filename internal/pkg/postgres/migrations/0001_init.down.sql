@@ -0,0 +1,13 @@
+-- The uuid-ossp extension is left in place; other schemas may rely on it.
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,180 @@
+-- Baseline schema. Every statement is guarded so a database previously built
+-- by GORM's AutoMigrate is adopted, and the columns that AutoMigrate never
+-- created there are added to its tables.
+
+-- users.id defaults to uuid_generate_v4().
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id         bigserial PRIMARY KEY,
+    name       text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_name ON permissions (name);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id         bigserial PRIMARY KEY,
+    name       text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_name ON roles (name);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id       bigint NOT NULL,
+    permission_id bigint NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role_permissions_role FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_role_permissions_permission FOREIGN KEY (permission_id) REFERENCES permissions (id)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name            text,
+    email           text,
+    pending_email   text,
+    password        text,
+    verified_at     timestamptz,
+    totp_secret     text,
+    totp_enabled_at timestamptz,
+    totp_last_step  bigint,
+    disabled_at     timestamptz,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email text;
+-- Accounts from before email verification count as verified; otherwise
+-- USER_REQUIRE_VERIFIED_EMAIL would lock every one of them out.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN verified_at timestamptz;
+        UPDATE users SET verified_at = coalesce(created_at, now());
+    END IF;
+END $$;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- An email may be registered again once its previous account is deleted.
+-- Older schemas have a plain unique index under the same name. Live accounts
+-- sharing an email would fail the index build with a bare constraint error,
+-- so name them and leave the merge to the operator.
+DO $$
+DECLARE
+    duplicates text;
+BEGIN
+    SELECT string_agg(email, ', ' ORDER BY email) INTO duplicates
+    FROM (
+        SELECT email FROM users WHERE deleted_at IS NULL GROUP BY email HAVING count(*) > 1
+    ) AS shared;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'live users share an email, merge or delete them before migrating: %', duplicates;
+    END IF;
+END $$;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id uuid   NOT NULL,
+    role_id bigint NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_user_roles_role FOREIGN KEY (role_id) REFERENCES roles (id)
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id         bigserial PRIMARY KEY,
+    name       text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id          bigserial PRIMARY KEY,
+    title       text,
+    content     text,
+    slug        text,
+    author_id   uuid,
+    category_id bigint,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE SET NULL
+);
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+-- Purging a category must never take its posts with it. Older schemas
+-- cascade here, so the constraint is always rebuilt.
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS fk_posts_category;
+ALTER TABLE posts ADD CONSTRAINT fk_posts_category
+    FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE RESTRICT;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         uuid PRIMARY KEY,
+    family_id  uuid,
+    user_id    uuid,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid,
+    purpose    text,
+    token_hash text,
+    expires_at timestamptz,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid,
+    code_hash  text,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid,
+    provider   text,
+    subject    text,
+    email      text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid,
+    event      text,
+    ip         text,
+    detail     text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event ON audit_logs (event);
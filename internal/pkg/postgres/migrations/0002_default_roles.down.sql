@@ -0,0 +1,8 @@
+DELETE FROM role_permissions
+WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('category:write', 'user:manage'));
+
+DELETE FROM permissions WHERE name IN ('category:write', 'user:manage');
+
+DELETE FROM roles
+WHERE name IN ('admin', 'user')
+  AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.role_id = roles.id);
@@ -0,0 +1,16 @@
+-- Permission checks always need these roles to resolve against. Roles that
+-- already exist keep any extra permissions.
+INSERT INTO roles (name, created_at, updated_at)
+VALUES ('admin', now(), now()), ('user', now(), now())
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, created_at, updated_at)
+VALUES ('category:write', now(), now()), ('user:manage', now(), now())
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('category:write', 'user:manage')
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,11 @@
+-- Full-text search over posts. Each row keeps the text search configuration
+-- it was indexed with, so a deployment can switch SEARCH_LANGUAGE and
+-- re-index with: UPDATE posts SET search_language = '<language>';
+ALTER TABLE posts ADD COLUMN search_language regconfig NOT NULL DEFAULT 'english';
+
+ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_language, coalesce(title, '')), 'A') ||
+    setweight(to_tsvector(search_language, coalesce(content, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS post_slugs;
+DROP INDEX IF EXISTS idx_posts_slug;
@@ -0,0 +1,21 @@
+-- Give every post a slug and make slugs unique. Duplicates from before get
+-- their id appended, so all but the oldest change.
+UPDATE posts SET slug = 'post-' || id WHERE slug IS NULL OR slug = '';
+
+UPDATE posts p
+SET slug = p.slug || '-' || p.id
+FROM (SELECT id, row_number() OVER (PARTITION BY slug ORDER BY id) AS n FROM posts) d
+WHERE d.id = p.id AND d.n > 1;
+
+CREATE UNIQUE INDEX idx_posts_slug ON posts (slug);
+
+-- Slugs a post had before its title changed, kept so old links redirect.
+CREATE TABLE post_slugs (
+    id         bigserial PRIMARY KEY,
+    post_id    bigint NOT NULL,
+    slug       text NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_posts_slugs FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_post_slugs_post_id ON post_slugs (post_id);
+CREATE UNIQUE INDEX idx_post_slugs_slug ON post_slugs (slug);
@@ -0,0 +1,9 @@
+-- Draft, published and archived posts. Existing posts were all public, so they
+-- start out published as of their creation.
+ALTER TABLE posts ADD COLUMN status text NOT NULL DEFAULT 'published';
+ALTER TABLE posts ADD COLUMN published_at timestamptz;
+
+UPDATE posts SET published_at = created_at;
+
+-- Serves the visibility filter and the scheduler's search for due drafts.
+CREATE INDEX idx_posts_status_published_at ON posts (status, published_at);
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/entity"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Purger hard-deletes soft-deleted posts, categories and users once they are
+// older than the retention window.
+type Purger struct {
+	db     *gorm.DB
+	config config.Purge
+}
+
+func NewPurger(db *gorm.DB, cfg config.Purge) *Purger {
+	return &Purger{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// Run purges on every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	if p.config.Retention <= 0 || p.config.Interval <= 0 {
+		log.Info().Msg("soft delete purge is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Purge(ctx, time.Now().Add(-p.config.Retention)); err != nil {
+			log.Error().Err(err).Msg("failed to purge soft-deleted rows")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes everything deleted before cutoff. Posts go first so that
+// categories they pointed at can follow; a category still referenced by a
+// post inside the window waits for a later run.
+func (p *Purger) Purge(ctx context.Context, cutoff time.Time) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		posts := tx.Unscoped().
+			Where("deleted_at < ?", cutoff).
+			Delete(&entity.Post{})
+		if posts.Error != nil {
+			return posts.Error
+		}
+
+		categories := tx.Unscoped().
+			Where("deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.category_id = categories.id)").
+			Delete(&entity.Category{})
+		if categories.Error != nil {
+			return categories.Error
+		}
+
+		purgedUsers := tx.Model(&entity.User{}).
+			Unscoped().
+			Select("id").
+			Where("deleted_at < ?", cutoff)
+
+		for _, table := range []string{"user_roles", "user_identities", "recovery_codes"} {
+			if err := tx.Table(table).Where("user_id IN (?)", purgedUsers).Delete(nil).Error; err != nil {
+				return err
+			}
+		}
+
+		users := tx.Unscoped().
+			Where("deleted_at < ?", cutoff).
+			Delete(&entity.User{})
+		if users.Error != nil {
+			return users.Error
+		}
+
+		if total := posts.RowsAffected + categories.RowsAffected + users.RowsAffected; total > 0 {
+			log.Info().
+				Int64("posts", posts.RowsAffected).
+				Int64("categories", categories.RowsAffected).
+				Int64("users", users.RowsAffected).
+				Msg("purged soft-deleted rows")
+		}
+
+		return nil
+	})
+}
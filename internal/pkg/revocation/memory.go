@@ -0,0 +1,78 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	at        time.Time
+	expiresAt time.Time
+}
+
+// memoryStore is an in-process Store used when Redis is not configured, e.g.
+// in tests or local development. Revocations are lost on restart and are not
+// shared between instances.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string]entry),
+	}
+}
+
+func (s *memoryStore) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	s.set(tokenKey(jti), time.Now(), ttl)
+	return nil
+}
+
+func (s *memoryStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok := s.get(tokenKey(jti))
+	return ok, nil
+}
+
+func (s *memoryStore) RevokeUser(ctx context.Context, userID string, at time.Time, ttl time.Duration) error {
+	s.set(userKey(userID), at, ttl)
+	return nil
+}
+
+func (s *memoryStore) UserRevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	e, ok := s.get(userKey(userID))
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	return e.at, nil
+}
+
+func (s *memoryStore) set(key string, at time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = entry{at: at, expiresAt: time.Now().Add(ttl)}
+}
+
+func (s *memoryStore) get(key string) (entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+
+	if time.Now().After(e.expiresAt) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+
+	return e, true
+}
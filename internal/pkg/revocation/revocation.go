@@ -0,0 +1,24 @@
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps track of access tokens that were revoked before their expiry.
+// Individual tokens are identified by their jti claim; RevokeUser records a
+// cut-off time so every token issued to that user up to that point is rejected.
+type Store interface {
+	RevokeToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUser(ctx context.Context, userID string, at time.Time, ttl time.Duration) error
+	UserRevokedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+func tokenKey(jti string) string {
+	return "revoked:token:" + jti
+}
+
+func userKey(userID string) string {
+	return "revoked:user:" + userID
+}
@@ -0,0 +1,40 @@
+package securetoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const tokenBytes = 32
+
+// Generator creates random single-use tokens, e.g. for email links. Only the
+// HMAC of a token is meant to be stored, so a leaked table cannot be used to
+// forge valid links without the secret.
+type Generator struct {
+	secret []byte
+}
+
+func New(secret string) *Generator {
+	return &Generator{secret: []byte(secret)}
+}
+
+// Generate returns a new URL-safe token together with its hash.
+func (g *Generator) Generate() (string, string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, g.Hash(token), nil
+}
+
+// Hash returns the value stored for a token.
+func (g *Generator) Hash(token string) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,67 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength caps a slug in runes, before any uniqueness suffix.
+const MaxLength = 80
+
+// fallback is used when nothing of the text survives.
+const fallback = "post"
+
+// replacements spell out letters that do not decompose into a base letter
+// plus accents.
+var replacements = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ð", "d",
+	"ł", "l", "þ", "th", "ı", "i", "&", " and ",
+)
+
+// stripMarks decomposes accented letters and drops the accents, so "é"
+// becomes "e". Compatibility decomposition also folds ligatures and
+// full-width forms ("ﬁ" to "fi", "Ａ" to "A").
+var stripMarks = transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Make turns text into a lower-case, URL-safe slug of letters and digits
+// separated by single dashes. Latin text is transliterated to ASCII; letters
+// of other scripts are kept as they are.
+func Make(text string) string {
+	folded, _, err := transform.String(stripMarks, strings.ToLower(text))
+	if err != nil {
+		folded = strings.ToLower(text)
+	}
+	folded = replacements.Replace(folded)
+
+	var b strings.Builder
+	dash := false
+	n := 0
+	for _, r := range folded {
+		if n >= MaxLength {
+			break
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				n++
+			}
+			b.WriteRune(r)
+			n++
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	if b.Len() == 0 {
+		return fallback
+	}
+
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello World", "hello-world"},
+		{"  Hello,   World!  ", "hello-world"},
+		{"Go 1.23 released", "go-1-23-released"},
+		{"Crème Brûlée", "creme-brulee"},
+		{"Straße", "strasse"},
+		{"Æsir & Œuvre", "aesir-and-oeuvre"},
+		{"Łódź", "lodz"},
+		{"ﬁnal Ａpp", "final-app"},
+		{"Привет мир", "привет-мир"},
+		{"日本語 タイトル", "日本語-タイトル"},
+		{"--already-a-slug--", "already-a-slug"},
+		{"!!!", "post"},
+		{"", "post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Make(tt.text); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeMaxLength(t *testing.T) {
+	got := Make(strings.Repeat("ab ", 100))
+
+	if n := utf8.RuneCountInString(got); n > MaxLength {
+		t.Errorf("slug has %d runes, want at most %d", n, MaxLength)
+	}
+
+	if strings.HasSuffix(got, "-") {
+		t.Errorf("slug %q ends with a dash", got)
+	}
+}
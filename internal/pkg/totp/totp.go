@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parameters understood by every common authenticator app (RFC 6238 defaults).
+const (
+	secretBytes = 20
+	period      = 30
+	digits      = 6
+	skew        = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded shared secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// URL builds the otpauth:// URI that authenticator apps read from a QR code.
+func URL(issuer string, account string, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(digits))
+	q.Set("period", fmt.Sprint(period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// Validate checks a code against the secret, allowing one step of clock
+// drift either way. It returns the matching time step so callers can refuse
+// a code that was already used.
+func Validate(secret string, code string, t time.Time) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != digits {
+		return 0, false
+	}
+
+	current := t.Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func generate(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     int64
+		step   int64
+		ok     bool
+	}{
+		// The last six digits of the RFC 6238 appendix B SHA-1 values.
+		{"rfc 59", rfcSecret, "287082", 59, 1, true},
+		{"rfc 1111111109", rfcSecret, "081804", 1111111109, 37037036, true},
+		{"rfc 1234567890", rfcSecret, "005924", 1234567890, 41152263, true},
+		{"rfc 2000000000", rfcSecret, "279037", 2000000000, 66666666, true},
+		{"lower-case secret", strings.ToLower(rfcSecret), "005924", 1234567890, 41152263, true},
+		{"previous step", rfcSecret, "005924", 1234567890 + 30, 41152263, true},
+		{"next step", rfcSecret, "005924", 1234567890 - 30, 41152263, true},
+		{"two steps late", rfcSecret, "005924", 1234567890 + 60, 0, false},
+		{"two steps early", rfcSecret, "005924", 1234567890 - 60, 0, false},
+		{"wrong code", rfcSecret, "005925", 1234567890, 0, false},
+		{"short code", rfcSecret, "05924", 1234567890, 0, false},
+		{"long code", rfcSecret, "0005924", 1234567890, 0, false},
+		{"empty code", rfcSecret, "", 1234567890, 0, false},
+		{"invalid secret", "not base32!", "005924", 1234567890, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, time.Unix(tt.at, 0))
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("Validate() = (%d, %v), want (%d, %v)", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretBytes {
+		t.Fatalf("secret %q does not decode to %d bytes: %v", secret, secretBytes, err)
+	}
+
+	if _, ok := Validate(secret, generate(key, now.Unix()/period), now); !ok {
+		t.Error("a code generated from a new secret does not validate")
+	}
+}
@@ -1,6 +1,20 @@
 package validator
 
-import "github.com/go-playground/validator"
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator"
+)
+
+const (
+	// PasswordMinLength is the shortest password the "password" rule accepts.
+	PasswordMinLength = 8
+	// PasswordMaxLength is bcrypt's input limit; anything past it is ignored
+	// when hashing, so it is rejected up front.
+	PasswordMaxLength = 72
+)
 
 type Validator struct {
 	validate *validator.Validate
@@ -8,11 +22,26 @@ type Validator struct {
 
 func NewValidator() *Validator {
 	v := validator.New()
+
+	// Report fields by their JSON name so clients see the keys they sent.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("password", isStrongPassword)
+
 	return &Validator{
 		validate: v,
 	}
 }
 
+// ValidateStruct checks s against its validate tags. Failed rules are
+// returned as validator.ValidationErrors, which resp.WriteError answers with
+// a 422 listing every field.
 func (v *Validator) ValidateStruct(s interface{}) error {
 	err := v.validate.Struct(s)
 	if err != nil {
@@ -20,3 +49,26 @@ func (v *Validator) ValidateStruct(s interface{}) error {
 	}
 	return nil
 }
+
+// isStrongPassword requires PasswordMinLength to PasswordMaxLength bytes with
+// at least one upper case letter, one lower case letter and one digit.
+func isStrongPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < PasswordMinLength || len(password) > PasswordMaxLength {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+
+	return upper && lower && digit
+}
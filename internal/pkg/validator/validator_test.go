@@ -0,0 +1,51 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator"
+)
+
+type passwordRequest struct {
+	Password string `json:"password" validate:"password"`
+}
+
+func TestPasswordRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"strong", "Passw0rd", true},
+		{"non-ascii letters", "Pässwört1", true},
+		{"longest", "Aa1" + strings.Repeat("x", PasswordMaxLength-3), true},
+		{"empty", "", false},
+		{"too short", "Pass0rd", false},
+		{"too long", "Aa1" + strings.Repeat("x", PasswordMaxLength-2), false},
+		{"no upper case", "passw0rd", false},
+		{"no lower case", "PASSW0RD", false},
+		{"no digit", "Password", false},
+		{"only digits", "12345678", false},
+	}
+
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateStruct(passwordRequest{Password: tt.password})
+			if valid := err == nil; valid != tt.valid {
+				t.Fatalf("ValidateStruct() error = %v, want valid %v", err, tt.valid)
+			}
+
+			if err == nil {
+				return
+			}
+
+			var errs validator.ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field() != "password" || errs[0].Tag() != "password" {
+				t.Errorf("error = %v, want one failed password rule on the password field", err)
+			}
+		})
+	}
+}
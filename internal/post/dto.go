@@ -1,21 +1,42 @@
 package post
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreatePostRequest publishes right away unless Status says otherwise. A
+// draft with PublishedAt is scheduled to be published then.
 type CreatePostRequest struct {
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	CategoryID int    `json:"category_id"`
+	Title       string     `json:"title" validate:"required,max=255"`
+	Content     string     `json:"content" validate:"required"`
+	CategoryID  int        `json:"category_id" validate:"required,gt=0"`
+	Status      string     `json:"status" validate:"omitempty,oneof=draft published archived"`
+	PublishedAt *time.Time `json:"published_at"`
 }
 
+// UpdatePostRequest keeps the current status when Status is empty, and a
+// scheduled draft keeps its PublishedAt when none is sent.
 type UpdatePostRequest struct {
-	ID         int    `json:"id"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	CategoryID int    `json:"category_id"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title" validate:"required,max=255"`
+	Content     string     `json:"content" validate:"required"`
+	CategoryID  int        `json:"category_id" validate:"required,gt=0"`
+	Status      string     `json:"status" validate:"omitempty,oneof=draft published archived"`
+	PublishedAt *time.Time `json:"published_at"`
 }
 
 type PostResponse struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Slug    string `json:"slug"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Slug        string     `json:"slug"`
+	AuthorID    *uuid.UUID `json:"author_id"`
+	Status      string     `json:"status"`
+	PublishedAt *time.Time `json:"published_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// Headline highlights the matches with <mark> when searching with q. The
+	// rest of it is HTML-escaped, so it is safe to render as HTML.
+	Headline string `json:"headline,omitempty"`
 }
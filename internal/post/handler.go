@@ -2,22 +2,44 @@ package post
 
 import (
 	"encoding/json"
+	"errors"
+	"net-http-boilerplate/internal/api/query"
 	"net-http-boilerplate/internal/api/resp"
 	"net-http-boilerplate/internal/entity"
 	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/cursor"
+	"net-http-boilerplate/internal/pkg/validator"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/rs/zerolog/log"
 )
 
+// sortable maps the accepted sort_by values to columns.
+var sortable = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type httpHandler struct {
-	service *Service
+	service   *Service
+	validator *validator.Validator
+	cursors   *cursor.Codec
+	list      query.Options
 }
 
-func NewPostHandler(service *Service) *httpHandler {
+func NewPostHandler(service *Service, validator *validator.Validator, cursors *cursor.Codec) *httpHandler {
 	return &httpHandler{
-		service: service,
+		service:   service,
+		validator: validator,
+		cursors:   cursors,
+		list: query.Options{
+			Sortable: sortable,
+			Cursors:  cursors,
+		},
 	}
 }
 
@@ -27,7 +49,12 @@ func (h *httpHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req CreatePostRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("failed to decode request")
-		resp.WriteJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid request"})
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "invalid request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
 		return
 	}
 
@@ -44,38 +71,15 @@ func (h *httpHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *httpHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	pageStr := r.URL.Query().Get("page")
-	perPageStr := r.URL.Query().Get("perPage")
-
-	if pageStr == "" {
-		pageStr = "1"
-	}
-	if perPageStr == "" {
-		perPageStr = "10"
-	}
-
-	pageInt, err := strconv.Atoi(pageStr)
-	if err != nil {
-		log.Ctx(ctx).Err(err).Msg("invalid 'page' query param")
-		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "'page' must be a number"))
-		return
-	}
-
-	perPageInt, err := strconv.Atoi(perPageStr)
+	filter, err := query.ParseFilter(r, h.list)
 	if err != nil {
-		log.Ctx(ctx).Err(err).Msg("invalid 'perPage' query param")
-		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "'perPage' must be a number"))
+		resp.WriteError(w, err)
 		return
 	}
 
-	filter := &entity.Filter{
-		Page:    &pageInt,
-		PerPage: &perPageInt,
-	}
-
 	posts, stats, err := h.service.FindAll(ctx, filter)
 	if err != nil {
-		if err == apperror.ErrResourceNotFound {
+		if errors.Is(err, apperror.ErrResourceNotFound) {
 			log.Ctx(ctx).Error().Err(err).Msg("no posts found")
 			resp.WriteJSONWithPaginateResponse(w, http.StatusOK, "success", posts, stats)
 			return
@@ -86,6 +90,11 @@ func (h *httpHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if filter.Limit != nil {
+		resp.WriteJSONWithCursorResponse(w, http.StatusOK, "success", posts, h.cursors.Encode(stats.Next), h.cursors.Encode(stats.Prev))
+		return
+	}
+
 	resp.WriteJSONWithPaginateResponse(w, http.StatusOK, "success", posts, stats)
 }
 
@@ -103,17 +112,31 @@ func (h *httpHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 
 	post, err := h.service.FindByID(ctx, id)
 	if err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("post not found")
-			resp.WriteError(w, resp.NewError(http.StatusNotFound, "post not found"))
-			return
-		}
+		log.Ctx(ctx).Error().Err(err).Msgf("failed to fetch post: %v", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", post)
+}
+
+// FindBySlug returns a post by its slug. A slug the post had before its
+// title changed answers 301 with the current URL.
+func (h *httpHandler) FindBySlug(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
+	post, current, err := h.service.FindBySlug(ctx, r.PathValue("slug"))
+	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to fetch post: %v", err)
 		resp.WriteError(w, err)
 		return
 	}
 
+	if current != "" {
+		http.Redirect(w, r, "/posts/slug/"+url.PathEscape(current), http.StatusMovedPermanently)
+		return
+	}
+
 	resp.WriteSuccess(w, http.StatusOK, "success", post)
 }
 
@@ -136,20 +159,21 @@ func (h *httpHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
 	post := &entity.Post{
-		ID:         id,
-		Title:      req.Title,
-		Content:    req.Content,
-		CategoryID: req.CategoryID,
+		ID:          id,
+		Title:       req.Title,
+		Content:     req.Content,
+		CategoryID:  req.CategoryID,
+		Status:      req.Status,
+		PublishedAt: req.PublishedAt,
 	}
 
 	if err := h.service.Update(ctx, post); err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("post not found")
-			resp.WriteError(w, resp.NewError(http.StatusBadRequest, "post not found"))
-			return
-		}
-
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to update post: %v", err)
 		resp.WriteError(w, err)
 		return
@@ -171,12 +195,6 @@ func (h *httpHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.Delete(ctx, id); err != nil {
-		if err == apperror.ErrResourceNotFound {
-			log.Ctx(ctx).Error().Err(err).Msg("post not found")
-			resp.WriteError(w, resp.NewError(http.StatusNotFound, "post not found"))
-			return
-		}
-
 		log.Ctx(ctx).Error().Err(err).Msgf("failed to delete post: %v", err)
 		resp.WriteError(w, err)
 		return
@@ -184,3 +202,25 @@ func (h *httpHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	resp.WriteSuccess(w, http.StatusOK, "success", nil)
 }
+
+func (h *httpHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	idStr := r.PathValue("id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("invalid id")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	post, err := h.service.Restore(ctx, id)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("failed to restore post: %v", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", post)
+}
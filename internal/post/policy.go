@@ -0,0 +1,28 @@
+package post
+
+import (
+	"context"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+)
+
+// canModify allows the author of a post, or an admin, to update or delete it.
+var canModify auth.Policy[*entity.Post] = func(p *auth.Principal, post *entity.Post) bool {
+	return auth.IsOwnerOrAdmin(p, post.AuthorID)
+}
+
+// canView shows published posts to everyone and the others only to their
+// author and admins.
+var canView auth.Policy[*entity.Post] = func(p *auth.Principal, post *entity.Post) bool {
+	return post.Status == entity.PostStatusPublished || auth.IsOwnerOrAdmin(p, post.AuthorID)
+}
+
+func authorize(ctx context.Context, policy auth.Policy[*entity.Post], post *entity.Post) error {
+	principal, _ := auth.UserFromContext(ctx)
+	if !policy(principal, post) {
+		return apperror.Forbidden("you are not allowed to modify this post")
+	}
+
+	return nil
+}
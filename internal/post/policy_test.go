@@ -0,0 +1,48 @@
+package post
+
+import (
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPolicies(t *testing.T) {
+	post := func(status string, authorID *uuid.UUID) *entity.Post {
+		return &entity.Post{Status: status, AuthorID: authorID}
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		post      *entity.Post
+		view      bool
+		modify    bool
+	}{
+		{"author, published", author, post(entity.PostStatusPublished, &author.ID), true, true},
+		{"author, draft", author, post(entity.PostStatusDraft, &author.ID), true, true},
+		{"author, archived", author, post(entity.PostStatusArchived, &author.ID), true, true},
+		{"other user, published", other, post(entity.PostStatusPublished, &author.ID), true, false},
+		{"other user, draft", other, post(entity.PostStatusDraft, &author.ID), false, false},
+		{"other user, archived", other, post(entity.PostStatusArchived, &author.ID), false, false},
+		{"admin, draft", admin, post(entity.PostStatusDraft, &author.ID), true, true},
+		{"admin, post without author", admin, post(entity.PostStatusDraft, nil), true, true},
+		{"user, post without author", author, post(entity.PostStatusDraft, nil), false, false},
+		{"user, published post without author", author, post(entity.PostStatusPublished, nil), true, false},
+		{"anonymous, published", nil, post(entity.PostStatusPublished, &author.ID), true, false},
+		{"anonymous, draft", nil, post(entity.PostStatusDraft, &author.ID), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canView(tt.principal, tt.post); got != tt.view {
+				t.Errorf("canView() = %v, want %v", got, tt.view)
+			}
+
+			if got := canModify(tt.principal, tt.post); got != tt.modify {
+				t.Errorf("canModify() = %v, want %v", got, tt.modify)
+			}
+		})
+	}
+}
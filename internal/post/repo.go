@@ -2,87 +2,99 @@ package post
 
 import (
 	"context"
+	"net-http-boilerplate/internal/config"
 	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/postgres"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// headlineOptions shape the ts_headline snippet returned with search results.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10"
+
 type Repository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	search config.Search
 }
 
-func NewPostRepository(db *gorm.DB) *Repository {
+func NewPostRepository(db *gorm.DB, search config.Search) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		search: search,
 	}
 }
 
 func (r *Repository) Create(ctx context.Context, post *entity.Post) error {
+	if post.SearchLanguage == "" {
+		post.SearchLanguage = r.search.Language
+	}
+
 	return r.db.WithContext(ctx).Create(post).Error
 }
 
 func (r *Repository) FindAll(ctx context.Context, filter *entity.Filter) ([]entity.Post, *entity.Stats, error) {
-	var res []entity.Post
-	var total int64
-
 	query := r.db.WithContext(ctx).Model(&entity.Post{})
 
 	if filter.CategoryID != nil {
 		query = query.Where("category_id = ?", *filter.CategoryID)
 	}
 
-	if filter.UserID != nil {
-		query = query.Where("user_id = ?", *filter.UserID)
+	if filter.AuthorID != nil {
+		query = query.Where("author_id = ?", *filter.AuthorID)
 	}
 
-	if filter.StartDate != nil && filter.EndDate != nil {
-		query = query.Where("created_at BETWEEN ? AND ?", filter.StartDate, filter.EndDate)
-	} else if filter.StartDate != nil {
-		query = query.Where("created_at >= ?", filter.StartDate)
-	} else if filter.EndDate != nil {
-		query = query.Where("created_at <= ?", filter.EndDate)
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
 	}
 
-	// Count total items
-	if err := query.Count(&total).Error; err != nil {
-		return nil, nil, err
+	if filter.VisibleTo != nil {
+		query = query.Where("status = ? OR author_id = ?", entity.PostStatusPublished, *filter.VisibleTo)
 	}
 
-	if filter.SortBy != nil && *filter.SortBy != "" {
-		sortOrder := "ASC"
-		if filter.SortOrder != nil && (*filter.SortOrder == "ASC" || *filter.SortOrder == "DESC") {
-			sortOrder = *filter.SortOrder
-		}
-		query = query.Order(*filter.SortBy + " " + sortOrder)
-	} else {
-		query = query.Order("created_at ASC")
+	opts := postgres.ListOptions{
+		Rows: func(query *gorm.DB) *gorm.DB {
+			return r.selectSearch(query, filter)
+		},
 	}
 
-	// Pagination logic
-	if filter.Page != nil && filter.PerPage != nil {
-		page := *filter.Page
-		perPage := *filter.PerPage
-		offset := (page - 1) * perPage
-
-		if err := query.Limit(perPage).Offset(offset).Find(&res).Error; err != nil {
-			return nil, nil, err
-		}
-
-		stats := &entity.Stats{
-			Page:  page,
-			Total: int(total),
-			Limit: perPage,
+	// Full-text search over the generated, GIN-indexed search_vector column.
+	// websearch_to_tsquery accepts "quoted phrases", OR and -excluded words.
+	// The query is parsed with SEARCH_LANGUAGE, not each row's search_language,
+	// so the index stays usable. Rows indexed with another configuration only
+	// match reliably once re-indexed, see migration 0003.
+	if filter.Query != nil {
+		query = query.Where("search_vector @@ websearch_to_tsquery(?::regconfig, ?)", r.search.Language, *filter.Query)
+
+		// Best matches first unless the client picked an order.
+		if filter.SortBy == nil {
+			opts.OrderFirst = []clause.OrderByColumn{{Column: clause.Column{Name: "search_rank"}, Desc: true}}
 		}
-		return res, stats, nil
 	}
 
-	// If no pagination, return all data
-	if err := query.Find(&res).Error; err != nil {
-		return nil, nil, err
-	}
+	return postgres.List[entity.Post](query, filter, opts)
+}
 
-	return res, nil, nil
+// escapedContent HTML-escapes the post content in SQL. ts_headline returns its
+// input as is, so without it the headline, which clients render as HTML for
+// the <mark> highlights, would carry any markup a post contains.
+const escapedContent = `replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// selectSearch adds the rank and highlighted snippet of every match when
+// searching. Both parse the query like the WHERE clause in FindAll does, so
+// the snippet highlights the words that made the post match.
+func (r *Repository) selectSearch(query *gorm.DB, filter *entity.Filter) *gorm.DB {
+	if filter.Query == nil {
+		return query
+	}
 
+	return query.Select(
+		"posts.*, "+
+			"ts_rank(search_vector, websearch_to_tsquery(?::regconfig, ?)) AS search_rank, "+
+			"ts_headline(?::regconfig, "+escapedContent+", websearch_to_tsquery(?::regconfig, ?), ?) AS headline",
+		r.search.Language, *filter.Query, r.search.Language, r.search.Language, *filter.Query, headlineOptions,
+	)
 }
 
 func (r *Repository) FindByCategory(ctx context.Context, category string) ([]entity.Post, error) {
@@ -91,6 +103,8 @@ func (r *Repository) FindByCategory(ctx context.Context, category string) ([]ent
 		SELECT * FROM posts
 		JOIN categories ON posts.category_id = categories.id
 		WHERE categories.name = ?
+		AND posts.deleted_at IS NULL
+		AND categories.deleted_at IS NULL
 	`
 
 	err := r.db.
@@ -107,10 +121,91 @@ func (r *Repository) FindByID(ctx context.Context, id int) (*entity.Post, error)
 	return &post, err
 }
 
-func (r *Repository) Update(ctx context.Context, post *entity.Post) error {
-	return r.db.WithContext(ctx).Save(post).Error
+// Update saves the post. When its slug changed, previousSlug is kept in the
+// slug history so links to it keep working, and a history entry for the new
+// slug is dropped in case the post takes back an old slug of its own.
+func (r *Repository) Update(ctx context.Context, post *entity.Post, previousSlug string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if previousSlug != "" && previousSlug != post.Slug {
+			if err := tx.Where("post_id = ? AND slug = ?", post.ID, post.Slug).Delete(&entity.PostSlug{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(&entity.PostSlug{PostID: post.ID, Slug: previousSlug}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(post).Error
+	})
+}
+
+// PublishDue publishes the drafts scheduled at or before now and returns how
+// many there were.
+func (r *Repository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.Post{}).
+		Where("status = ? AND published_at <= ?", entity.PostStatusDraft, now).
+		Update("status", entity.PostStatusPublished)
+	return res.RowsAffected, res.Error
+}
+
+func (r *Repository) FindBySlug(ctx context.Context, slug string) (*entity.Post, error) {
+	var post entity.Post
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&post).Error
+	return &post, err
+}
+
+// FindBySlugHistory returns the live post that used to have slug.
+func (r *Repository) FindBySlugHistory(ctx context.Context, slug string) (*entity.Post, error) {
+	var post entity.Post
+	err := r.db.WithContext(ctx).
+		Select("posts.*").
+		Joins("JOIN post_slugs ON post_slugs.post_id = posts.id").
+		Where("post_slugs.slug = ?", slug).
+		First(&post).
+		Error
+	return &post, err
+}
+
+// SlugsTaken returns base and the base-N slugs that other posts use, now or
+// in their history. Deleted posts keep their slugs until they are purged.
+func (r *Repository) SlugsTaken(ctx context.Context, base string, postID int) ([]string, error) {
+	var slugs []string
+	err := r.db.WithContext(ctx).
+		Raw(`
+			SELECT slug FROM posts WHERE (slug = ? OR slug LIKE ?) AND id <> ?
+			UNION
+			SELECT slug FROM post_slugs WHERE (slug = ? OR slug LIKE ?) AND post_id <> ?
+		`, base, base+"-%", postID, base, base+"-%", postID).
+		Scan(&slugs).
+		Error
+	return slugs, err
 }
 
 func (r *Repository) Delete(ctx context.Context, id int) error {
 	return r.db.WithContext(ctx).Delete(&entity.Post{}, id).Error
 }
+
+// FindDeletedByID returns a post only if it is soft-deleted.
+func (r *Repository) FindDeletedByID(ctx context.Context, id int) (*entity.Post, error) {
+	var post entity.Post
+	err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&post, id).Error
+	return &post, err
+}
+
+func (r *Repository) Restore(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Model(&entity.Post{}).
+		Where("id = ?", id).
+		Update("deleted_at", nil).
+		Error
+}
+
+// CategoryExists reports whether the category is there and not deleted.
+func (r *Repository) CategoryExists(ctx context.Context, categoryID int) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.Category{}).Where("id = ?", categoryID).Count(&count).Error
+	return count > 0, err
+}
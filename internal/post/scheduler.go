@@ -0,0 +1,48 @@
+package post
+
+import (
+	"context"
+	"net-http-boilerplate/internal/config"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Scheduler publishes drafts once their published_at comes due.
+type Scheduler struct {
+	repo   Repo
+	config config.Publishing
+}
+
+func NewScheduler(repo Repo, cfg config.Publishing) *Scheduler {
+	return &Scheduler{
+		repo:   repo,
+		config: cfg,
+	}
+}
+
+// Run publishes due posts on every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.config.Interval <= 0 {
+		log.Info().Msg("scheduled publishing is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := s.repo.PublishDue(ctx, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("failed to publish scheduled posts")
+		} else if published > 0 {
+			log.Info().Int64("count", published).Msg("published scheduled posts")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
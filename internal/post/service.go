@@ -2,13 +2,30 @@ package post
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/auth"
 	"net-http-boilerplate/internal/entity"
 	apperror "net-http-boilerplate/internal/pkg/app-error"
-	"strings"
+	"net-http-boilerplate/internal/pkg/slug"
+	"slices"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// maxSlugAttempts bounds the retries of a create that lost a slug race.
+const maxSlugAttempts = 3
+
+var (
+	errPostNotFound     = apperror.NotFound("post not found")
+	errCategoryNotFound = apperror.Validation("category does not exist")
+	errCategoryDeleted  = apperror.Conflict("the post's category is deleted, restore it first")
+	errScheduleInPast   = apperror.Validation("'published_at' must be in the future to schedule a draft")
+	errPublishInFuture  = apperror.Validation("'published_at' cannot be in the future for a published post, save it as a draft to schedule it")
+)
+
 type Service struct {
 	repo Repo
 }
@@ -18,8 +35,15 @@ type Repo interface {
 	FindAll(ctx context.Context, filter *entity.Filter) ([]entity.Post, *entity.Stats, error)
 	FindByCategory(ctx context.Context, category string) ([]entity.Post, error)
 	FindByID(ctx context.Context, id int) (*entity.Post, error)
-	Update(ctx context.Context, post *entity.Post) error
+	Update(ctx context.Context, post *entity.Post, previousSlug string) error
+	FindBySlug(ctx context.Context, slug string) (*entity.Post, error)
+	FindBySlugHistory(ctx context.Context, slug string) (*entity.Post, error)
+	SlugsTaken(ctx context.Context, base string, postID int) ([]string, error)
 	Delete(ctx context.Context, id int) error
+	FindDeletedByID(ctx context.Context, id int) (*entity.Post, error)
+	Restore(ctx context.Context, id int) error
+	CategoryExists(ctx context.Context, categoryID int) (bool, error)
+	PublishDue(ctx context.Context, now time.Time) (int64, error)
 }
 
 func NewPostService(repo Repo) *Service {
@@ -33,34 +57,63 @@ func (s *Service) Create(ctx context.Context, req *CreatePostRequest) (*entity.P
 		Title:      req.Title,
 		Content:    req.Content,
 		CategoryID: req.CategoryID,
+		Status:     entity.PostStatusPublished,
+	}
+
+	if principal, ok := auth.UserFromContext(ctx); ok {
+		post.AuthorID = &principal.ID
+	}
+
+	if err := setStatus(post, req.Status, req.PublishedAt); err != nil {
+		return nil, err
 	}
 
-	slug := strings.ReplaceAll(strings.ToLower(post.Title), " ", "-")
-	post.Slug = slug
-	if err := s.repo.Create(ctx, post); err != nil {
+	if err := s.checkCategory(ctx, post.CategoryID); err != nil {
 		return nil, err
 	}
 
-	return post, nil
+	// Another post may take the same slug between the check and the insert;
+	// the unique index catches that and the next free suffix is tried.
+	for attempt := 1; ; attempt++ {
+		newSlug, err := s.uniqueSlug(ctx, post.Title, 0)
+		if err != nil {
+			return nil, err
+		}
+		post.Slug = newSlug
+
+		err = s.repo.Create(ctx, post)
+		if err == nil {
+			return post, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || attempt == maxSlugAttempts {
+			return nil, translateWriteError(err)
+		}
+	}
 }
 
+// FindAll lists posts. Apart from admins, users only see published posts and
+// their own.
 func (s *Service) FindAll(ctx context.Context, filter *entity.Filter) ([]PostResponse, *entity.Stats, error) {
+	principal, ok := auth.UserFromContext(ctx)
+	if !principal.IsAdmin() {
+		viewer := uuid.Nil
+		if ok {
+			viewer = principal.ID
+		}
+		filter.VisibleTo = &viewer
+	}
+
 	posts, stats, err := s.repo.FindAll(ctx, filter)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, stats, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, stats, errPostNotFound
 		}
 		return nil, stats, err
 	}
 
 	var res []PostResponse
 	for _, post := range posts {
-		res = append(res, PostResponse{
-			ID:      post.ID,
-			Title:   post.Title,
-			Content: post.Content,
-			Slug:    post.Slug,
-		})
+		res = append(res, *newPostResponse(&post))
 	}
 
 	return res, stats, nil
@@ -69,37 +122,252 @@ func (s *Service) FindAll(ctx context.Context, filter *entity.Filter) ([]PostRes
 func (s *Service) FindByID(ctx context.Context, id int) (*PostResponse, error) {
 	post, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errPostNotFound
 		}
 		return nil, err
 	}
-	return &PostResponse{
-		ID:      post.ID,
-		Title:   post.Title,
-		Content: post.Content,
-		Slug:    post.Slug,
-	}, nil
+
+	if !visible(ctx, post) {
+		return nil, errPostNotFound
+	}
+
+	return newPostResponse(post), nil
+}
+
+// FindBySlug returns the post with the given slug. For a slug the post had
+// before its title changed it returns the current slug to redirect to
+// instead.
+func (s *Service) FindBySlug(ctx context.Context, slug string) (*PostResponse, string, error) {
+	post, err := s.repo.FindBySlug(ctx, slug)
+	if err == nil {
+		if !visible(ctx, post) {
+			return nil, "", errPostNotFound
+		}
+		return newPostResponse(post), "", nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+
+	renamed, err := s.repo.FindBySlugHistory(ctx, slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", errPostNotFound
+		}
+		return nil, "", err
+	}
+
+	// The current slug gives away the title of a post the caller cannot see.
+	if !visible(ctx, renamed) {
+		return nil, "", errPostNotFound
+	}
+
+	return nil, renamed.Slug, nil
 }
 
 func (s *Service) Update(ctx context.Context, post *entity.Post) error {
-	post.Slug = strings.ReplaceAll(strings.ToLower(post.Title), " ", "-")
-	err := s.repo.Update(ctx, post)
+	existing, err := s.repo.FindByID(ctx, post.ID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errPostNotFound
 		}
+		return err
 	}
-	return err
+
+	// Only a post the caller can see answers 403 rather than 404.
+	if !visible(ctx, existing) {
+		return errPostNotFound
+	}
+
+	if err := authorize(ctx, canModify, existing); err != nil {
+		return err
+	}
+
+	if err := s.checkCategory(ctx, post.CategoryID); err != nil {
+		return err
+	}
+
+	previousSlug := existing.Slug
+	if post.Title != existing.Title {
+		newSlug, err := s.uniqueSlug(ctx, post.Title, existing.ID)
+		if err != nil {
+			return err
+		}
+		existing.Slug = newSlug
+	}
+
+	if err := setStatus(existing, post.Status, post.PublishedAt); err != nil {
+		return err
+	}
+
+	existing.Title = post.Title
+	existing.Content = post.Content
+	existing.CategoryID = post.CategoryID
+	if err := s.repo.Update(ctx, existing, previousSlug); err != nil {
+		return translateWriteError(err)
+	}
+
+	*post = *existing
+	return nil
 }
 
 func (s *Service) Delete(ctx context.Context, id int) error {
-	err := s.repo.Delete(ctx, id)
+	existing, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errPostNotFound
 		}
 		return err
 	}
+
+	if !visible(ctx, existing) {
+		return errPostNotFound
+	}
+
+	if err := authorize(ctx, canModify, existing); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(ctx, existing.ID)
+}
+
+// Restore undoes a soft delete. Like Delete it is limited to the author and
+// admins, and the post's category must not be deleted itself.
+func (s *Service) Restore(ctx context.Context, id int) (*PostResponse, error) {
+	deleted, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errPostNotFound
+		}
+		return nil, err
+	}
+
+	if !visible(ctx, deleted) {
+		return nil, errPostNotFound
+	}
+
+	if err := authorize(ctx, canModify, deleted); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.CategoryExists(ctx, deleted.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errCategoryDeleted
+	}
+
+	if err := s.repo.Restore(ctx, deleted.ID); err != nil {
+		return nil, err
+	}
+
+	return s.FindByID(ctx, deleted.ID)
+}
+
+// setStatus moves the post to status, keeping the current one when status is
+// empty. A draft with a future publishedAt is scheduled and published by the
+// Scheduler, and stays scheduled when updated without one; publishing stamps
+// published_at unless the post was published before. Archived posts keep it.
+func setStatus(post *entity.Post, status string, publishedAt *time.Time) error {
+	if status == "" {
+		status = post.Status
+	}
+
+	now := time.Now()
+	switch status {
+	case entity.PostStatusDraft:
+		if publishedAt != nil && !publishedAt.After(now) {
+			return errScheduleInPast
+		}
+		// A post that becomes a draft loses its old published_at, which the
+		// Scheduler would otherwise take as due right away.
+		if publishedAt != nil || post.Status != entity.PostStatusDraft {
+			post.PublishedAt = publishedAt
+		}
+	case entity.PostStatusPublished:
+		if publishedAt != nil && publishedAt.After(now) {
+			return errPublishInFuture
+		}
+		if publishedAt != nil {
+			post.PublishedAt = publishedAt
+		} else if post.Status != entity.PostStatusPublished || post.PublishedAt == nil {
+			post.PublishedAt = &now
+		}
+	}
+
+	post.Status = status
 	return nil
 }
+
+// visible hides unpublished posts from everyone but their author and admins.
+func visible(ctx context.Context, post *entity.Post) bool {
+	principal, _ := auth.UserFromContext(ctx)
+	return canView(principal, post)
+}
+
+// uniqueSlug returns the slug for title, suffixed with -2, -3, ... when
+// another post already uses it. postID is the post being renamed, whose own
+// current and old slugs are free to reuse.
+func (s *Service) uniqueSlug(ctx context.Context, title string, postID int) (string, error) {
+	base := slug.Make(title)
+
+	taken, err := s.repo.SlugsTaken(ctx, base, postID)
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(taken, base) {
+		return base, nil
+	}
+
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", base, n)
+		if !slices.Contains(taken, candidate) {
+			return candidate, nil
+		}
+	}
+}
+
+// checkCategory rejects categories that were never there or are deleted; the
+// foreign key alone lets soft-deleted ones through.
+func (s *Service) checkCategory(ctx context.Context, categoryID int) error {
+	exists, err := s.repo.CategoryExists(ctx, categoryID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errCategoryNotFound
+	}
+
+	return nil
+}
+
+func newPostResponse(post *entity.Post) *PostResponse {
+	return &PostResponse{
+		ID:          post.ID,
+		Title:       post.Title,
+		Content:     post.Content,
+		Slug:        post.Slug,
+		AuthorID:    post.AuthorID,
+		Status:      post.Status,
+		PublishedAt: post.PublishedAt,
+		DeletedAt:   entity.DeletedTime(post.DeletedAt),
+		Headline:    post.Headline,
+	}
+}
+
+// translateWriteError reports a post pointing at a missing category, or
+// losing a slug race, as a client error instead of a 500.
+func translateWriteError(err error) error {
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return apperror.Wrap(err, apperror.KindValidation, errCategoryNotFound.Message)
+	}
+
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return apperror.Wrap(err, apperror.KindConflict, "another post just took this slug, try again")
+	}
+
+	return err
+}
@@ -0,0 +1,277 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeRepo keeps posts in memory. Deleted posts stay in the map with
+// DeletedAt set, like a soft delete.
+type fakeRepo struct {
+	posts map[int]*entity.Post
+}
+
+func newFakeRepo(posts ...*entity.Post) *fakeRepo {
+	r := &fakeRepo{posts: make(map[int]*entity.Post)}
+	for _, post := range posts {
+		r.posts[post.ID] = post
+	}
+	return r
+}
+
+func (r *fakeRepo) find(id int, deleted bool) (*entity.Post, error) {
+	post, ok := r.posts[id]
+	if !ok || post.DeletedAt.Valid != deleted {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	copied := *post
+	return &copied, nil
+}
+
+func (r *fakeRepo) Create(ctx context.Context, post *entity.Post) error {
+	post.ID = len(r.posts) + 1
+	r.posts[post.ID] = post
+	return nil
+}
+
+func (r *fakeRepo) FindAll(ctx context.Context, filter *entity.Filter) ([]entity.Post, *entity.Stats, error) {
+	var res []entity.Post
+	for id := 1; id <= len(r.posts); id++ {
+		post := r.posts[id]
+		if post.DeletedAt.Valid && !filter.IncludeDeleted {
+			continue
+		}
+		res = append(res, *post)
+	}
+	return res, nil, nil
+}
+
+func (r *fakeRepo) FindByCategory(ctx context.Context, category string) ([]entity.Post, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id int) (*entity.Post, error) {
+	return r.find(id, false)
+}
+
+func (r *fakeRepo) Update(ctx context.Context, post *entity.Post, previousSlug string) error {
+	copied := *post
+	r.posts[post.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepo) FindBySlug(ctx context.Context, slug string) (*entity.Post, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRepo) FindBySlugHistory(ctx context.Context, slug string) (*entity.Post, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRepo) SlugsTaken(ctx context.Context, base string, postID int) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) Delete(ctx context.Context, id int) error {
+	r.posts[id].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (r *fakeRepo) FindDeletedByID(ctx context.Context, id int) (*entity.Post, error) {
+	return r.find(id, true)
+}
+
+func (r *fakeRepo) Restore(ctx context.Context, id int) error {
+	r.posts[id].DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+func (r *fakeRepo) CategoryExists(ctx context.Context, categoryID int) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeRepo) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+// principals are the callers of the service tests. author owns every post.
+var (
+	author = &auth.Principal{ID: uuid.New(), Roles: []string{entity.RoleUser}}
+	other  = &auth.Principal{ID: uuid.New(), Roles: []string{entity.RoleUser}}
+	admin  = &auth.Principal{ID: uuid.New(), Roles: []string{entity.RoleAdmin}}
+)
+
+func testPost(status string) *entity.Post {
+	return &entity.Post{
+		ID:         1,
+		Title:      "Hello",
+		Content:    "World",
+		Slug:       "hello",
+		AuthorID:   &author.ID,
+		CategoryID: 1,
+		Status:     status,
+	}
+}
+
+func contextFor(p *auth.Principal) context.Context {
+	if p == nil {
+		return context.Background()
+	}
+	return auth.WithUser(context.Background(), p)
+}
+
+func TestServiceUpdateOwnership(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		err       error
+	}{
+		{"author", author, nil},
+		{"admin", admin, nil},
+		{"other user", other, apperror.ErrForbidden},
+		{"anonymous", nil, apperror.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo(testPost(entity.PostStatusPublished))
+			service := NewPostService(repo)
+
+			err := service.Update(contextFor(tt.principal), &entity.Post{ID: 1, Title: "Hello", Content: "Changed", CategoryID: 1})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.err)
+			}
+
+			want := "World"
+			if tt.err == nil {
+				want = "Changed"
+			}
+			if got := repo.posts[1].Content; got != want {
+				t.Errorf("content = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestServiceDeleteOwnership(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		err       error
+	}{
+		{"author", author, nil},
+		{"admin", admin, nil},
+		{"other user", other, apperror.ErrForbidden},
+		{"anonymous", nil, apperror.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo(testPost(entity.PostStatusPublished))
+			service := NewPostService(repo)
+
+			err := service.Delete(contextFor(tt.principal), 1)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.err)
+			}
+
+			if deleted := repo.posts[1].DeletedAt.Valid; deleted != (tt.err == nil) {
+				t.Errorf("deleted = %v, want %v", deleted, tt.err == nil)
+			}
+		})
+	}
+}
+
+func TestServiceUpdateMissingPost(t *testing.T) {
+	service := NewPostService(newFakeRepo())
+
+	err := service.Update(contextFor(author), &entity.Post{ID: 1, Title: "Hello", Content: "World", CategoryID: 1})
+	if !errors.Is(err, apperror.ErrResourceNotFound) {
+		t.Errorf("Update() error = %v, want %v", err, apperror.ErrResourceNotFound)
+	}
+}
+
+// TestServiceHidesUnpublishedPosts checks that a user who cannot see a post
+// gets 404 from the write paths too, instead of a 403 that gives it away.
+func TestServiceHidesUnpublishedPosts(t *testing.T) {
+	update := func(ctx context.Context, s *Service) error {
+		return s.Update(ctx, &entity.Post{ID: 1, Title: "Hello", Content: "Changed", CategoryID: 1})
+	}
+	remove := func(ctx context.Context, s *Service) error {
+		return s.Delete(ctx, 1)
+	}
+	restore := func(ctx context.Context, s *Service) error {
+		_, err := s.Restore(ctx, 1)
+		return err
+	}
+
+	tests := []struct {
+		name      string
+		status    string
+		deleted   bool
+		principal *auth.Principal
+		call      func(context.Context, *Service) error
+		err       error
+	}{
+		{"update another user's draft", entity.PostStatusDraft, false, other, update, apperror.ErrResourceNotFound},
+		{"update another user's archived post", entity.PostStatusArchived, false, other, update, apperror.ErrResourceNotFound},
+		{"update another user's published post", entity.PostStatusPublished, false, other, update, apperror.ErrForbidden},
+		{"update own draft", entity.PostStatusDraft, false, author, update, nil},
+		{"admin updates a draft", entity.PostStatusDraft, false, admin, update, nil},
+		{"delete another user's draft", entity.PostStatusDraft, false, other, remove, apperror.ErrResourceNotFound},
+		{"delete another user's published post", entity.PostStatusPublished, false, other, remove, apperror.ErrForbidden},
+		{"delete own draft", entity.PostStatusDraft, false, author, remove, nil},
+		{"restore another user's draft", entity.PostStatusDraft, true, other, restore, apperror.ErrResourceNotFound},
+		{"restore another user's published post", entity.PostStatusPublished, true, other, restore, apperror.ErrForbidden},
+		{"restore own draft", entity.PostStatusDraft, true, author, restore, nil},
+		{"admin restores a draft", entity.PostStatusDraft, true, admin, restore, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := testPost(tt.status)
+			if tt.deleted {
+				post.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			}
+			service := NewPostService(newFakeRepo(post))
+
+			if err := tt.call(contextFor(tt.principal), service); !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestServiceFindAllResponse(t *testing.T) {
+	deletedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	post := testPost(entity.PostStatusPublished)
+	post.DeletedAt = gorm.DeletedAt{Time: deletedAt, Valid: true}
+	post.Headline = "<mark>Hello</mark>"
+	service := NewPostService(newFakeRepo(post))
+
+	res, _, err := service.FindAll(contextFor(admin), &entity.Filter{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 {
+		t.Fatalf("posts = %d, want 1", len(res))
+	}
+
+	if res[0].DeletedAt == nil || !res[0].DeletedAt.Equal(deletedAt) {
+		t.Errorf("deleted_at = %v, want %v", res[0].DeletedAt, deletedAt)
+	}
+
+	if res[0].Headline != post.Headline || res[0].Slug != post.Slug {
+		t.Errorf("response = %+v, want the headline and slug of the post", res[0])
+	}
+}
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListUsers returns a page of users, searched by name or email when the
+// filter has a query.
+func (s *Service) ListUsers(ctx context.Context, filter *entity.Filter) ([]AdminUserResponse, *entity.Stats, error) {
+	users, stats, err := s.repo.FindAll(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([]AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		res = append(res, newAdminUserResponse(&user))
+	}
+
+	return res, stats, nil
+}
+
+func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (*AdminUserResponse, error) {
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := newAdminUserResponse(user)
+	return &res, nil
+}
+
+// DisableUser blocks the user from signing in and ends their sessions.
+func (s *Service) DisableUser(ctx context.Context, admin *auth.Principal, id uuid.UUID) error {
+	if admin.ID == id {
+		return errSelfManagement
+	}
+
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.repo.SetDisabled(ctx, user.ID, &now); err != nil {
+		return err
+	}
+
+	if err := s.revokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, user, entity.AuditEventUserDisabled, "", fmt.Sprintf("by=%s", admin.ID))
+	return nil
+}
+
+// EnableUser lifts a previous DisableUser.
+func (s *Service) EnableUser(ctx context.Context, admin *auth.Principal, id uuid.UUID) error {
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetDisabled(ctx, user.ID, nil); err != nil {
+		return err
+	}
+
+	s.audit(ctx, user, entity.AuditEventUserEnabled, "", fmt.Sprintf("by=%s", admin.ID))
+	return nil
+}
+
+func (s *Service) DeleteUser(ctx context.Context, admin *auth.Principal, id uuid.UUID) error {
+	if admin.ID == id {
+		return errSelfManagement
+	}
+
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := s.revocation.RevokeUser(ctx, user.ID.String(), time.Now(), s.jwt.AccessTokenTTL()); err != nil {
+		return err
+	}
+
+	s.audit(ctx, nil, entity.AuditEventUserDeleted, "", fmt.Sprintf("user=%s email=%s by=%s", user.ID, user.Email, admin.ID))
+	return nil
+}
+
+// RestoreUser undoes a deletion within the retention window. It fails with a
+// conflict if the email was registered again in the meantime.
+func (s *Service) RestoreUser(ctx context.Context, admin *auth.Principal, id uuid.UUID) error {
+	user, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUserNotFound
+		}
+		return err
+	}
+
+	if err := s.repo.Restore(ctx, user.ID); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errEmailTaken
+		}
+		return err
+	}
+
+	s.audit(ctx, user, entity.AuditEventUserRestored, "", fmt.Sprintf("by=%s", admin.ID))
+	return nil
+}
+
+// RevokeUserSessions signs the user out of every session without disabling
+// the account.
+func (s *Service) RevokeUserSessions(ctx context.Context, admin *auth.Principal, id uuid.UUID) error {
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, user, entity.AuditEventSessionsRevoked, "", fmt.Sprintf("by=%s", admin.ID))
+	return nil
+}
+
+func (s *Service) findUserByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func newAdminUserResponse(user *entity.User) AdminUserResponse {
+	return AdminUserResponse{
+		ProfileResponse: *newProfileResponse(user),
+		DisabledAt:      user.DisabledAt,
+		DeletedAt:       entity.DeletedTime(user.DeletedAt),
+	}
+}
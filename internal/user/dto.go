@@ -1,18 +1,118 @@
 package user
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,password"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=32"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type DisableTOTPRequest struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=32"`
+}
+
+type UpdateProfileRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+type DeleteAccountRequest struct {
 	Password string `json:"password"`
 }
 
+type ProfileResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	PendingEmail string     `json:"pending_email,omitempty"`
+	VerifiedAt   *time.Time `json:"verified_at"`
+	MFAEnabled   bool       `json:"mfa_enabled"`
+	Roles        []string   `json:"roles"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// AdminUserResponse is the view of a user in the admin API.
+type AdminUserResponse struct {
+	ProfileResponse
+	DisabledAt *time.Time `json:"disabled_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// UserResponse is returned by every login step. When MFARequired is set the
+// token fields are empty and MFAToken must be exchanged at /users/login/mfa.
 type UserResponse struct {
-	Email       string `json:"email"`
-	Name        string `json:"name"`
-	AccessToken string `json:"access_token"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
 }
@@ -1,24 +1,51 @@
 package user
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net-http-boilerplate/internal/api/query"
 	"net-http-boilerplate/internal/api/resp"
-	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/pkg/cursor"
+	"net-http-boilerplate/internal/pkg/lockout"
 	"net-http-boilerplate/internal/pkg/validator"
 	"net/http"
+	"strconv"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
+const oauthStateCookie = "oauth_state"
+
+// sortable maps the accepted sort_by values of the admin user list to columns.
+var sortable = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type httpHandler struct {
 	service   *Service
 	validator *validator.Validator
+	cursors   *cursor.Codec
+	list      query.Options
 }
 
-func NewUserHandler(service *Service, validator *validator.Validator) *httpHandler {
+func NewUserHandler(service *Service, validator *validator.Validator, cursors *cursor.Codec) *httpHandler {
 	return &httpHandler{
 		service:   service,
 		validator: validator,
+		cursors:   cursors,
+		list: query.Options{
+			Sortable: sortable,
+			Cursors:  cursors,
+		},
 	}
 }
 
@@ -31,6 +58,11 @@ func (h *httpHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
 	if err := h.service.Register(ctx, &req); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msgf("cannot register new user: %s", err)
 		resp.WriteError(w, err)
@@ -44,23 +76,606 @@ func (h *httpHandler) Login(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	data, err := h.service.Login(ctx, req, clientIP(r))
+	if err != nil {
+		setRetryAfter(w, err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	data, err := h.service.Refresh(ctx, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot refresh token: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	// The refresh token is optional, an empty body only revokes the access token.
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.service.Logout(ctx, principal, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot logout: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	if err := h.service.LogoutAll(ctx, principal); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot logout from all sessions: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.VerifyEmail(ctx, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot verify email: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.ResendVerification(ctx, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot resend verification: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "if the account exists and is not verified, a new link has been sent", nil)
+}
+
+func (h *httpHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	h.service.ForgotPassword(ctx, req)
+
+	resp.WriteSuccess(w, http.StatusOK, "if the account exists, a password reset link has been sent", nil)
+}
+
+func (h *httpHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.ResetPassword(ctx, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot reset password: %s", err)
 		resp.WriteError(w, err)
 		return
 	}
 
-	data, err := h.service.Login(ctx, req)
-	switch err {
-	case nil:
-	case apperror.ErrResourceNotFound:
-		resp.WriteJSON(w, http.StatusNotFound, map[string]string{"message": "user not found"})
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
 		return
-	case apperror.ErrInvalidPassword:
-		resp.WriteJSON(w, http.StatusUnauthorized, map[string]string{"message": "invalid password"})
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
 		return
-	default:
+	}
+
+	data, err := h.service.LoginMFA(ctx, req, clientIP(r))
+	if err != nil {
+		setRetryAfter(w, err)
 		resp.WriteError(w, err)
 		return
 	}
 
 	resp.WriteSuccess(w, http.StatusOK, "success", data)
 }
+
+func (h *httpHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	data, err := h.service.EnrollTOTP(ctx, principal)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot enroll totp: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	data, err := h.service.ConfirmTOTP(ctx, principal, req, clientIP(r))
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot confirm totp: %s", err)
+		setRetryAfter(w, err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.DisableTOTP(ctx, principal, req, clientIP(r)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot disable totp: %s", err)
+		setRetryAfter(w, err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) Me(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	data, err := h.service.Me(ctx, principal)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot get profile: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	data, err := h.service.UpdateProfile(ctx, principal, req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot update profile: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.ChangeEmail(ctx, principal, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot change email: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusAccepted, "confirmation sent to the new email address", nil)
+}
+
+func (h *httpHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.ConfirmEmailChange(ctx, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot confirm email change: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.ChangePassword(ctx, principal, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot change password: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("cannot decode request")
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "bad request"))
+		return
+	}
+
+	if err := h.validator.ValidateStruct(req); err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	if err := h.service.DeleteAccount(ctx, principal, req); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot delete account: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, err := query.ParseFilter(r, h.list)
+	if err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	// Newest accounts first unless the client picked an order.
+	if filter.SortOrder == nil {
+		desc := "DESC"
+		filter.SortOrder = &desc
+	}
+
+	users, stats, err := h.service.ListUsers(ctx, filter)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot list users: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	if filter.Limit != nil {
+		resp.WriteJSONWithCursorResponse(w, http.StatusOK, "success", users, h.cursors.Encode(stats.Next), h.cursors.Encode(stats.Prev))
+		return
+	}
+
+	resp.WriteJSONWithPaginateResponse(w, http.StatusOK, "success", users, stats)
+}
+
+func (h *httpHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	data, err := h.service.GetUser(ctx, id)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot get user: %s", err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+func (h *httpHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.adminAction(w, r, "disable user", h.service.DisableUser)
+}
+
+func (h *httpHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.adminAction(w, r, "enable user", h.service.EnableUser)
+}
+
+func (h *httpHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.adminAction(w, r, "delete user", h.service.DeleteUser)
+}
+
+func (h *httpHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	h.adminAction(w, r, "restore user", h.service.RestoreUser)
+}
+
+func (h *httpHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	h.adminAction(w, r, "revoke user sessions", h.service.RevokeUserSessions)
+}
+
+// adminAction runs an admin operation on the user named by the {id} path
+// value.
+func (h *httpHandler) adminAction(w http.ResponseWriter, r *http.Request, name string, action func(context.Context, *auth.Principal, uuid.UUID) error) {
+	ctx := r.Context()
+	principal, ok := auth.UserFromContext(ctx)
+	if !ok {
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "unauthorized"))
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	if err := action(ctx, principal, id); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("cannot %s: %s", name, err)
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", nil)
+}
+
+func (h *httpHandler) OAuthStart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := r.PathValue("provider")
+
+	redirectURL, state, err := h.service.OAuthStart(provider)
+	if err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/users/oauth/" + provider,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	log.Ctx(ctx).Info().Str("provider", provider).Msg("redirecting to oauth provider")
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+func (h *httpHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := r.PathValue("provider")
+	query := r.URL.Query()
+
+	if errCode := query.Get("error"); errCode != "" {
+		log.Ctx(ctx).Error().Str("provider", provider).Str("error", errCode).Msg("oauth provider returned an error")
+		resp.WriteError(w, resp.NewError(http.StatusUnauthorized, "login was cancelled or denied"))
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		resp.WriteError(w, resp.NewError(http.StatusBadRequest, "missing oauth state"))
+		return
+	}
+
+	// The state is single use, clear it whatever the outcome.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     "/users/oauth/" + provider,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	data, err := h.service.OAuthCallback(ctx, provider, query.Get("code"), query.Get("state"), cookie.Value)
+	if err != nil {
+		resp.WriteError(w, err)
+		return
+	}
+
+	resp.WriteSuccess(w, http.StatusOK, "success", data)
+}
+
+// setRetryAfter tells the client when to retry if err is a login lockout.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var locked *lockout.LockedError
+	if errors.As(err, &locked) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	}
+}
+
+// clientIP returns the caller's address without the port. realIPHandler has
+// already replaced RemoteAddr with the IP reported by a trusted proxy when
+// there is one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
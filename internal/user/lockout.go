@@ -0,0 +1,94 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net-http-boilerplate/internal/entity"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// compareDummyPassword spends the same time as a real password check so
+// unknown emails cannot be told apart by response time.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func accountLockoutKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLoginAllowed fails with a rate-limited error wrapping a
+// lockout.LockedError while either the
+// account or the client IP is locked.
+func (s *Service) checkLoginAllowed(ctx context.Context, email string, ip string) error {
+	return s.guard.Check(ctx, accountLockoutKey(email), ipLockoutKey(ip))
+}
+
+// recordLoginFailure counts a failed login for the account and the IP and
+// writes an audit entry whenever one of them gets locked. user is nil when
+// the email does not belong to an account.
+func (s *Service) recordLoginFailure(ctx context.Context, user *entity.User, email string, ip string) {
+	accountLock, err := s.guard.Fail(ctx, accountLockoutKey(email), s.lockout.MaxAccountAttempts)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record failed login for account")
+	}
+
+	if accountLock > 0 {
+		s.audit(ctx, user, entity.AuditEventAccountLocked, ip, fmt.Sprintf("email=%s locked_for=%s", email, accountLock))
+	}
+
+	if ip == "" {
+		return
+	}
+
+	ipLock, err := s.guard.Fail(ctx, ipLockoutKey(ip), s.lockout.MaxIPAttempts)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record failed login for ip")
+	}
+
+	if ipLock > 0 {
+		s.audit(ctx, nil, entity.AuditEventIPLocked, ip, fmt.Sprintf("locked_for=%s", ipLock))
+	}
+}
+
+func (s *Service) resetLoginFailures(ctx context.Context, email string) {
+	if err := s.guard.Reset(ctx, accountLockoutKey(email)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to reset failed logins for account")
+	}
+}
+
+func (s *Service) audit(ctx context.Context, user *entity.User, event string, ip string, detail string) {
+	entry := &entity.AuditLog{
+		Event:     event,
+		IP:        ip,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		entry.UserID = &user.ID
+	}
+
+	log.Ctx(ctx).Warn().Str("event", event).Str("ip", ip).Str("detail", detail).Msg("audit")
+
+	if err := s.repo.CreateAuditLog(ctx, entry); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to write audit log")
+	}
+}
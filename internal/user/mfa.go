@@ -0,0 +1,273 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/encrypt"
+	"net-http-boilerplate/internal/pkg/totp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// LoginMFA completes a login that was answered with an MFA challenge, using
+// either a TOTP code or one of the user's recovery codes.
+func (s *Service) LoginMFA(ctx context.Context, req MFALoginRequest, ip string) (*UserResponse, error) {
+	claims, err := s.jwt.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return nil, errInvalidMFAToken
+	}
+
+	revoked, err := s.revocation.IsTokenRevoked(ctx, claims.RegisteredClaims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errInvalidMFAToken
+	}
+
+	userID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, errInvalidMFAToken
+	}
+
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return nil, errInvalidMFAToken
+	}
+
+	if err := s.checkLoginAllowed(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	// Guessing codes counts against the same limits as guessing passwords.
+	if err := s.verifySecondFactor(ctx, user, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, apperror.ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, user, user.Email, ip)
+		}
+		return nil, err
+	}
+
+	s.resetLoginFailures(ctx, user.Email)
+
+	// A challenge can only be completed once.
+	if err := s.revocation.RevokeToken(ctx, claims.RegisteredClaims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
+// EnrollTOTP creates a new TOTP secret for the user. It is not enforced
+// until ConfirmTOTP proves the authenticator app was set up correctly.
+func (s *Service) EnrollTOTP(ctx context.Context, principal *auth.Principal) (*TOTPEnrollResponse, error) {
+	user, err := s.repo.FindByID(ctx, principal.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, apperror.ErrMFAAlreadyActive
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedSecret, err := encrypt.EncryptData(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetTOTPSecret(ctx, user.ID, encryptedSecret); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURL: totp.URL(s.config.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication after checking a first code
+// and returns freshly generated recovery codes. They are only shown once.
+// Wrong codes count against the login lockout.
+func (s *Service) ConfirmTOTP(ctx context.Context, principal *auth.Principal, req TOTPCodeRequest, ip string) (*RecoveryCodesResponse, error) {
+	user, err := s.repo.FindByID(ctx, principal.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, apperror.ErrMFAAlreadyActive
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, apperror.ErrMFANotEnrolled
+	}
+
+	if err := s.checkLoginAllowed(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	secret, err := encrypt.DecryptData(user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		s.recordLoginFailure(ctx, user, user.Email, ip)
+		return nil, apperror.ErrInvalidMFACode
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, s.tokens.Hash(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.repo.EnableTOTP(ctx, user.ID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off. It requires a valid
+// second factor so a stolen access token alone cannot remove it, and wrong
+// codes count against the login lockout so it cannot be guessed either.
+func (s *Service) DisableTOTP(ctx context.Context, principal *auth.Principal, req DisableTOTPRequest, ip string) error {
+	user, err := s.repo.FindByID(ctx, principal.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUserNotFound
+		}
+		return err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return apperror.ErrMFANotEnrolled
+	}
+
+	if err := s.checkLoginAllowed(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	if err := s.verifySecondFactor(ctx, user, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, apperror.ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, user, user.Email, ip)
+		}
+		return err
+	}
+
+	return s.repo.DisableTOTP(ctx, user.ID)
+}
+
+func (s *Service) mfaChallenge(user *entity.User) (*UserResponse, error) {
+	token, err := s.jwt.GenerateMFAToken(user.ID.String(), user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserResponse{
+		Email:       user.Email,
+		Name:        user.Name,
+		MFARequired: true,
+		MFAToken:    token,
+	}, nil
+}
+
+func (s *Service) verifySecondFactor(ctx context.Context, user *entity.User, code string, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := s.repo.ConsumeRecoveryCode(ctx, user.ID, s.tokens.Hash(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return apperror.ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	secret, err := encrypt.DecryptData(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return apperror.ErrInvalidMFACode
+	}
+
+	// Reject a code whose time step was already accepted once.
+	fresh, err := s.repo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+
+	if !fresh {
+		return apperror.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// generateRecoveryCode returns a code formatted as two dash-separated halves,
+// e.g. "k3m9p-x2q7r", using characters that are hard to confuse.
+func generateRecoveryCode() (string, error) {
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	b := make([]byte, recoveryCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+
+	half := recoveryCodeLength / 2
+	return string(b[:half]) + "-" + string(b[half:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,227 @@
+package user
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/oauth"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// OAuthStart begins a login with the given provider. It returns the URL to
+// redirect the browser to and a signed state value, holding the CSRF state,
+// the PKCE verifier and the OIDC nonce, that the caller must keep in a
+// cookie until the callback.
+func (s *Service) OAuthStart(providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", errUnknownProvider
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, challenge, err := oauth.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(s.oauth.StateTTL).Unix(), 10)
+	payload := state + "." + verifier + "." + nonce + "." + expiresAt
+	signed := payload + "." + s.tokens.Hash(providerName+"."+payload)
+
+	return provider.AuthCodeURL(state, challenge, nonce), signed, nil
+}
+
+// OAuthCallback finishes the flow: it checks the state against the signed
+// cookie value, exchanges the code and signs the matching user in.
+func (s *Service) OAuthCallback(ctx context.Context, providerName string, code string, state string, signedState string) (*UserResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, errUnknownProvider
+	}
+
+	verifier, nonce, ok := s.verifyOAuthState(providerName, state, signedState)
+	if !ok {
+		return nil, errInvalidOAuthState
+	}
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("provider", providerName).Msg("oauth code exchange failed")
+		return nil, apperror.ErrOAuthFailed
+	}
+
+	identity, err := s.fetchIdentity(ctx, provider, token, nonce)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("provider", providerName).Msg("oauth identity check failed")
+		return nil, apperror.ErrOAuthFailed
+	}
+
+	user, err := s.userForIdentity(ctx, providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return s.mfaChallenge(user)
+	}
+
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
+// userForIdentity returns the user already linked to the identity. Otherwise
+// the identity is linked to the account with the same verified email, or a
+// new account is created for it.
+func (s *Service) userForIdentity(ctx context.Context, providerName string, identity *oauth.Identity) (*entity.User, error) {
+	linked, err := s.repo.FindIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		user, err := s.repo.FindByID(ctx, linked.UserID)
+		// The identity of a deleted account is kept until it is purged.
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errAccountDisabled
+		}
+		return user, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Only an email the provider has verified may be used to take over an
+	// existing account.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, apperror.ErrOAuthNoEmail
+	}
+
+	user, err := s.repo.FindByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Nobody proved they own the email of an unverified account, so it
+		// may have been registered by someone else ahead of its owner. The
+		// owner takes it over without anything that was set up before.
+		if user.VerifiedAt == nil {
+			if err := s.claimUnverified(ctx, user); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		now := time.Now()
+		user = &entity.User{
+			Name:       identity.Name,
+			Email:      identity.Email,
+			VerifiedAt: &now,
+		}
+		if err := s.repo.Create(ctx, user, entity.RoleUser); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return nil, errEmailTaken
+			}
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.repo.CreateIdentity(ctx, &entity.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, apperror.Conflict("this provider account is already linked")
+		}
+		return nil, err
+	}
+
+	return s.repo.FindByID(ctx, user.ID)
+}
+
+// claimUnverified hands an unverified account to the provider-verified owner
+// of its email, clearing its credentials and signing out its sessions.
+func (s *Service) claimUnverified(ctx context.Context, user *entity.User) error {
+	claimed, err := s.repo.ClaimUnverified(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	s.audit(ctx, user, entity.AuditEventAccountClaimed, "", "credentials of the unverified account were cleared")
+
+	return s.revokeSessions(ctx, user.ID)
+}
+
+// fetchIdentity takes the identity of an OIDC login from its verified ID
+// token. Plain OAuth2 providers such as GitHub only have their userinfo API.
+func (s *Service) fetchIdentity(ctx context.Context, provider *oauth.Provider, token *oauth.Token, nonce string) (*oauth.Identity, error) {
+	if !provider.OIDC() {
+		return provider.FetchIdentity(ctx, token.AccessToken)
+	}
+
+	identity, err := provider.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers leave the profile out of the ID token.
+	if identity.Name == "" {
+		if info, err := provider.FetchIdentity(ctx, token.AccessToken); err == nil && info.Subject == identity.Subject {
+			identity.Name = info.Name
+		}
+	}
+
+	return identity, nil
+}
+
+// verifyOAuthState returns the PKCE verifier and the nonce from the signed
+// state cookie if it belongs to this provider, matches the state sent back
+// and has not expired.
+func (s *Service) verifyOAuthState(providerName string, state string, signedState string) (string, string, bool) {
+	parts := strings.Split(signedState, ".")
+	if len(parts) != 5 {
+		return "", "", false
+	}
+
+	payload := strings.Join(parts[:4], ".")
+	expected := s.tokens.Hash(providerName + "." + payload)
+	if !hmac.Equal([]byte(parts[4]), []byte(expected)) {
+		return "", "", false
+	}
+
+	if !hmac.Equal([]byte(parts[0]), []byte(state)) {
+		return "", "", false
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", "", false
+	}
+
+	return parts[1], parts[2], true
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
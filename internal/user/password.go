@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/mailer"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ForgotPassword emails a password reset link if the address belongs to an
+// account. The lookup and delivery run in the background so neither the
+// response nor its timing reveal whether the email exists.
+func (s *Service) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) {
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		if err := s.sendPasswordReset(ctx, req.Email); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to send password reset email")
+		}
+	}()
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the
+// user out of every existing session.
+func (s *Service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	token, err := s.repo.ConsumeUserToken(ctx, entity.TokenPurposePasswordReset, s.tokens.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, token.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.repo.InvalidateUserTokens(ctx, token.UserID, entity.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	if err := s.revocation.RevokeUser(ctx, token.UserID.String(), time.Now(), s.jwt.AccessTokenTTL()); err != nil {
+		return err
+	}
+
+	return s.repo.RevokeUserRefreshTokens(ctx, token.UserID)
+}
+
+func (s *Service) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.repo.InvalidateUserTokens(ctx, user.ID, entity.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	token, hash, err := s.tokens.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateUserToken(ctx, &entity.UserToken{
+		UserID:    user.ID,
+		Purpose:   entity.TokenPurposePasswordReset,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.config.PasswordResetTTL),
+	}); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password:\n\n%s\n\nThis link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			user.Name, tokenLink(s.config.PasswordResetURL, token), s.config.PasswordResetTTL,
+		),
+	})
+}
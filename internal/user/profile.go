@@ -0,0 +1,218 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/mailer"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Me returns the profile of the authenticated user.
+func (s *Service) Me(ctx context.Context, principal *auth.Principal) (*ProfileResponse, error) {
+	user, err := s.findUser(ctx, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return newProfileResponse(user), nil
+}
+
+// UpdateProfile changes the fields a user may edit freely.
+func (s *Service) UpdateProfile(ctx context.Context, principal *auth.Principal, req UpdateProfileRequest) (*ProfileResponse, error) {
+	if err := s.repo.UpdateName(ctx, principal.ID, req.Name); err != nil {
+		return nil, err
+	}
+
+	return s.Me(ctx, principal)
+}
+
+// ChangeEmail starts an email change. The current address stays in use until
+// the link sent to the new one is confirmed with ConfirmEmailChange.
+func (s *Service) ChangeEmail(ctx context.Context, principal *auth.Principal, req ChangeEmailRequest) error {
+	user, err := s.findUser(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	if err := checkCurrentPassword(user, req.Password); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.FindByEmail(ctx, req.Email); err == nil {
+		return errEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err := s.repo.SetPendingEmail(ctx, user.ID, req.Email); err != nil {
+		return err
+	}
+
+	if err := s.repo.InvalidateUserTokens(ctx, user.ID, entity.TokenPurposeEmailChange); err != nil {
+		return err
+	}
+
+	token, hash, err := s.tokens.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateUserToken(ctx, &entity.UserToken{
+		UserID:    user.ID,
+		Purpose:   entity.TokenPurposeEmailChange,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.config.VerificationTokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      req.Email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your new email address:\n\n%s\n\nThis link expires in %s.\n",
+			user.Name, tokenLink(s.config.EmailChangeURL, token), s.config.VerificationTokenTTL,
+		),
+	}); err != nil {
+		return err
+	}
+
+	// Let the owner of the current address know in case this wasn't them.
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA change of your account email to %s was requested. If this wasn't you, change your password now.\n",
+			user.Name, req.Email,
+		),
+	}); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to send email change notice")
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange redeems an email change token, switches the account to
+// the new address and signs the user out everywhere, since existing tokens
+// still carry the old one.
+func (s *Service) ConfirmEmailChange(ctx context.Context, req ConfirmEmailChangeRequest) error {
+	token, err := s.repo.ConsumeUserToken(ctx, entity.TokenPurposeEmailChange, s.tokens.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidEmailChangeToken
+		}
+		return err
+	}
+
+	applied, err := s.repo.ApplyPendingEmail(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errEmailTaken
+		}
+		return err
+	}
+
+	if !applied {
+		return errInvalidEmailChangeToken
+	}
+
+	return s.revokeSessions(ctx, token.UserID)
+}
+
+// ChangePassword sets a new password after checking the current one, then
+// signs the user out everywhere. Accounts created through OAuth have no
+// password yet and set their first one with a password reset instead.
+func (s *Service) ChangePassword(ctx context.Context, principal *auth.Principal, req ChangePasswordRequest) error {
+	user, err := s.findUser(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	if err := checkCurrentPassword(user, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.repo.InvalidateUserTokens(ctx, user.ID, entity.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	return s.revokeSessions(ctx, user.ID)
+}
+
+// DeleteAccount removes the authenticated user after checking their password
+// and revokes every token they still hold.
+func (s *Service) DeleteAccount(ctx context.Context, principal *auth.Principal, req DeleteAccountRequest) error {
+	user, err := s.findUser(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	if err := checkCurrentPassword(user, req.Password); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+
+	return s.revocation.RevokeUser(ctx, user.ID.String(), time.Now(), s.jwt.AccessTokenTTL())
+}
+
+func (s *Service) findUser(ctx context.Context, principal *auth.Principal) (*entity.User, error) {
+	return s.findUserByID(ctx, principal.ID)
+}
+
+// revokeSessions invalidates every access and refresh token of the user.
+func (s *Service) revokeSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := s.revocation.RevokeUser(ctx, userID.String(), time.Now(), s.jwt.AccessTokenTTL()); err != nil {
+		return err
+	}
+
+	return s.repo.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// checkCurrentPassword guards sensitive changes. Users without a password
+// (OAuth-only accounts) have nothing to confirm with, so an access token
+// alone would do; they first set a password through the emailed reset link,
+// which proves they still own the account's address.
+func checkCurrentPassword(user *entity.User, password string) error {
+	if user.Password == "" {
+		return errPasswordNotSet
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return errWrongPassword
+	}
+
+	return nil
+}
+
+func newProfileResponse(user *entity.User) *ProfileResponse {
+	return &ProfileResponse{
+		ID:           user.ID,
+		Name:         user.Name,
+		Email:        user.Email,
+		PendingEmail: user.PendingEmail,
+		VerifiedAt:   user.VerifiedAt,
+		MFAEnabled:   user.TOTPEnabledAt != nil,
+		Roles:        roleNames(user),
+		CreatedAt:    user.CreatedAt,
+	}
+}
@@ -2,9 +2,14 @@ package user
 
 import (
 	"context"
+	"errors"
 	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/postgres"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
@@ -17,8 +22,21 @@ func NewUserRepository(db *gorm.DB) *Repository {
 	}
 }
 
-func (r *Repository) Create(ctx context.Context, user *entity.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+// Create inserts the user together with the role it starts with, so an
+// account never exists without one.
+func (r *Repository) Create(ctx context.Context, user *entity.User, roleName string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role entity.Role
+		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(user).Association("Roles").Append(&role)
+	})
 }
 
 func (r *Repository) Save(ctx context.Context, user *entity.User) error {
@@ -27,6 +45,342 @@ func (r *Repository) Save(ctx context.Context, user *entity.User) error {
 
 func (r *Repository) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var user entity.User
-	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Preload("Roles").Where("email = ?", email).First(&user).Error
+	return &user, err
+}
+
+func (r *Repository) FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var user entity.User
+	err := r.db.WithContext(ctx).Preload("Roles").Where("id = ?", id).First(&user).Error
+	return &user, err
+}
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *Repository) FindRefreshToken(ctx context.Context, id uuid.UUID) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&token).Error
+	return &token, err
+}
+
+// RevokeRefreshToken marks a single refresh token as used. It reports false
+// when the token had already been revoked, which lets the caller detect two
+// concurrent refreshes racing on the same token.
+func (r *Repository) RevokeRefreshToken(ctx context.Context, id uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+// PermissionsForRoles returns the distinct permission names granted by the
+// given roles.
+func (r *Repository) PermissionsForRoles(ctx context.Context, roles []string) ([]string, error) {
+	var permissions []string
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&entity.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name IN ?", roles).
+		Pluck("permissions.name", &permissions).
+		Error
+	return permissions, err
+}
+
+// IsDisabled reports whether the user was disabled. Unknown users count as
+// disabled so tokens of deleted accounts stop working.
+func (r *Repository) IsDisabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var user entity.User
+	err := r.db.WithContext(ctx).
+		Select("id", "disabled_at").
+		Where("id = ?", userID).
+		Take(&user).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return user.DisabledAt != nil, nil
+}
+
+// FindAll lists users for admins, optionally filtered by a case-insensitive
+// match on name or email.
+func (r *Repository) FindAll(ctx context.Context, filter *entity.Filter) ([]entity.User, *entity.Stats, error) {
+	query := r.db.WithContext(ctx).Model(&entity.User{})
+
+	if filter.Query != nil {
+		like := postgres.ContainsPattern(*filter.Query)
+		query = query.Where(`name ILIKE ? ESCAPE '\' OR email ILIKE ? ESCAPE '\'`, like, like)
+	}
+
+	return postgres.List[entity.User](query, filter, postgres.ListOptions{
+		Rows: func(query *gorm.DB) *gorm.DB {
+			return query.Preload("Roles")
+		},
+	})
+}
+
+// SetDisabled disables the user, or enables them again with a nil time.
+func (r *Repository) SetDisabled(ctx context.Context, userID uuid.UUID, disabledAt *time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ?", userID).
+		Update("disabled_at", disabledAt).
+		Error
+}
+
+func (r *Repository) CreateUserToken(ctx context.Context, token *entity.UserToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// Doing both in one statement guarantees a token is only ever redeemed once.
+func (r *Repository) ConsumeUserToken(ctx context.Context, purpose string, hash string) (*entity.UserToken, error) {
+	var token entity.UserToken
+	now := time.Now()
+	res := r.db.WithContext(ctx).
+		Model(&token).
+		Clauses(clause.Returning{}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, hash, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &token, nil
+}
+
+// InvalidateUserTokens marks every outstanding token of the given purpose as
+// used, so only the most recently sent link keeps working.
+func (r *Repository) InvalidateUserTokens(ctx context.Context, userID uuid.UUID, purpose string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).
+		Error
+}
+
+func (r *Repository) MarkVerified(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND verified_at IS NULL", userID).
+		Update("verified_at", time.Now()).
+		Error
+}
+
+// ClaimUnverified marks an unverified account verified for the owner of its
+// email and drops every credential set before that: the password, TOTP and
+// recovery codes, and a pending email change. It reports false if the
+// account was verified in the meantime.
+func (r *Repository) ClaimUnverified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	claimed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&entity.User{}).
+			Where("id = ? AND verified_at IS NULL", userID).
+			Updates(map[string]any{
+				"verified_at":     time.Now(),
+				"password":        "",
+				"pending_email":   "",
+				"totp_secret":     "",
+				"totp_enabled_at": nil,
+				"totp_last_step":  0,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		claimed = true
+
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&entity.UserToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", time.Now()).
+			Error
+	})
+	return claimed, err
+}
+
+func (r *Repository) UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ?", userID).
+		Update("password", hashedPassword).
+		Error
+}
+
+func (r *Repository) UpdateName(ctx context.Context, userID uuid.UUID, name string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ?", userID).
+		Update("name", name).
+		Error
+}
+
+func (r *Repository) SetPendingEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ?", userID).
+		Update("pending_email", email).
+		Error
+}
+
+// ApplyPendingEmail makes the pending email the user's verified address. It
+// reports false when there was no pending change to apply.
+func (r *Repository) ApplyPendingEmail(ctx context.Context, userID uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND pending_email <> ''", userID).
+		Updates(map[string]any{
+			"email":         gorm.Expr("pending_email"),
+			"pending_email": "",
+			"verified_at":   time.Now(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Delete soft-deletes the user and drops their refresh and emailed tokens.
+// Roles, identities and recovery codes are kept so the account can be
+// restored; the purge job removes them for good.
+func (r *Repository) Delete(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{
+			&entity.RefreshToken{},
+			&entity.UserToken{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&entity.User{ID: userID}).Error
+	})
+}
+
+// FindDeletedByID returns a user only if they are soft-deleted.
+func (r *Repository) FindDeletedByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var user entity.User
+	err := r.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&user).Error
 	return &user, err
 }
+
+func (r *Repository) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Model(&entity.User{}).
+		Where("id = ?", id).
+		Update("deleted_at", nil).
+		Error
+}
+
+func (r *Repository) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND totp_enabled_at IS NULL", userID).
+		Update("totp_secret", secret).
+		Error
+}
+
+// EnableTOTP turns on two-factor authentication and replaces any previous
+// recovery codes with the given hashes.
+func (r *Repository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]any{"totp_enabled_at": time.Now(), "totp_last_step": step}).
+			Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]entity.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, entity.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *Repository) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]any{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).
+			Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error
+	})
+}
+
+// UseTOTPStep records the time step of an accepted code. It reports false if
+// that step, or a later one, was already used, so a code cannot be replayed.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *Repository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *Repository) FindIdentity(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error) {
+	var identity entity.UserIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return &identity, err
+}
+
+func (r *Repository) CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *Repository) CreateAuditLog(ctx context.Context, entry *entity.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
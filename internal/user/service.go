@@ -2,29 +2,102 @@ package user
 
 import (
 	"context"
+	"errors"
+	"net-http-boilerplate/internal/auth"
+	"net-http-boilerplate/internal/config"
 	"net-http-boilerplate/internal/entity"
 	apperror "net-http-boilerplate/internal/pkg/app-error"
 	"net-http-boilerplate/internal/pkg/jwt"
+	"net-http-boilerplate/internal/pkg/lockout"
+	"net-http-boilerplate/internal/pkg/mailer"
+	"net-http-boilerplate/internal/pkg/oauth"
+	"net-http-boilerplate/internal/pkg/revocation"
+	"net-http-boilerplate/internal/pkg/securetoken"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// Token errors carry the flow they came from so clients get a useful detail;
+// all of them still match apperror.ErrInvalidToken.
+var (
+	errInvalidRefreshToken      = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindUnauthorized, "invalid refresh token")
+	errInvalidMFAToken          = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindUnauthorized, "invalid or expired mfa token")
+	errInvalidVerificationToken = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindValidation, "invalid or expired verification token")
+	errInvalidResetToken        = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindValidation, "invalid or expired reset token")
+	errInvalidOAuthState        = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindValidation, "invalid or expired oauth state")
+	errUserNotFound             = apperror.NotFound("user not found")
+	errInvalidEmailChangeToken  = apperror.Wrap(apperror.ErrInvalidToken, apperror.KindValidation, "invalid or expired email change token")
+	errWrongPassword            = apperror.Wrap(apperror.ErrInvalidPassword, apperror.KindForbidden, "current password is incorrect")
+	errPasswordNotSet           = apperror.Forbidden("set a password with the link from /users/password/forgot first")
+	errEmailTaken               = apperror.Conflict("email is already registered")
+	errAccountDisabled          = apperror.Forbidden("account is disabled")
+	errSelfManagement           = apperror.Validation("admins cannot disable or delete their own account")
+	errUnknownProvider          = apperror.Wrap(oauth.ErrUnknownProvider, apperror.KindNotFound, "unknown oauth provider")
+)
+
 type Service struct {
-	repo Repo
-	jwt  *jwt.JWT
+	repo       Repo
+	jwt        *jwt.JWT
+	revocation revocation.Store
+	mailer     mailer.Mailer
+	tokens     *securetoken.Generator
+	providers  map[string]*oauth.Provider
+	guard      *lockout.Guard
+	config     config.User
+	oauth      config.OAuth
+	lockout    config.Lockout
 }
 
 type Repo interface {
-	Create(ctx context.Context, user *entity.User) error
+	Create(ctx context.Context, user *entity.User, roleName string) error
 	Save(ctx context.Context, user *entity.User) error
 	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error
+	FindRefreshToken(ctx context.Context, id uuid.UUID) (*entity.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+	CreateUserToken(ctx context.Context, token *entity.UserToken) error
+	ConsumeUserToken(ctx context.Context, purpose string, hash string) (*entity.UserToken, error)
+	InvalidateUserTokens(ctx context.Context, userID uuid.UUID, purpose string) error
+	MarkVerified(ctx context.Context, userID uuid.UUID) error
+	ClaimUnverified(ctx context.Context, userID uuid.UUID) (bool, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, hashedPassword string) error
+	UpdateName(ctx context.Context, userID uuid.UUID, name string) error
+	SetPendingEmail(ctx context.Context, userID uuid.UUID, email string) error
+	ApplyPendingEmail(ctx context.Context, userID uuid.UUID) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+	FindAll(ctx context.Context, filter *entity.Filter) ([]entity.User, *entity.Stats, error)
+	SetDisabled(ctx context.Context, userID uuid.UUID, disabledAt *time.Time) error
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID uuid.UUID) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	FindIdentity(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error
+	CreateAuditLog(ctx context.Context, entry *entity.AuditLog) error
 }
 
-func NewUserService(repo Repo, jwt *jwt.JWT) *Service {
+func NewUserService(repo Repo, jwt *jwt.JWT, revocation revocation.Store, mailer mailer.Mailer, tokens *securetoken.Generator, guard *lockout.Guard, cfg *config.Config) *Service {
 	return &Service{
-		repo: repo,
-		jwt:  jwt,
+		repo:       repo,
+		jwt:        jwt,
+		revocation: revocation,
+		mailer:     mailer,
+		tokens:     tokens,
+		providers:  oauth.NewProviders(cfg.OAuth),
+		guard:      guard,
+		config:     cfg.User,
+		oauth:      cfg.OAuth,
+		lockout:    cfg.Lockout,
 	}
 }
 
@@ -41,34 +114,218 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) error {
 
 	user.Password = string(hashedPassword)
 
-	return s.repo.Create(ctx, user)
+	if err := s.repo.Create(ctx, user, entity.RoleUser); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errEmailTaken
+		}
+		return err
+	}
+
+	// The account exists at this point; a failed email can be retried
+	// through resend-verification.
+	if err := s.sendVerification(ctx, user); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to send verification email")
+	}
+
+	return nil
 }
 
-func (s *Service) Login(ctx context.Context, req LoginRequest) (*UserResponse, error) {
+// Login checks the credentials of a user. Unknown emails and wrong passwords
+// fail the same way, and repeated failures lock the account and the client
+// IP out with exponential backoff.
+func (s *Service) Login(ctx context.Context, req LoginRequest, ip string) (*UserResponse, error) {
+	if err := s.checkLoginAllowed(ctx, req.Email, ip); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, apperror.ErrResourceNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			compareDummyPassword(req.Password)
+			s.recordLoginFailure(ctx, nil, req.Email, ip)
+			return nil, apperror.ErrInvalidLogin
 		}
 		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, apperror.ErrInvalidPassword
+		s.recordLoginFailure(ctx, user, req.Email, ip)
+		return nil, apperror.ErrInvalidLogin
+	}
+
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
 	}
 
-	token, _, err := s.jwt.GenerateToken(user.ID.String(), user.Email)
+	if s.config.RequireVerifiedEmail && user.VerifiedAt == nil {
+		return nil, apperror.ErrEmailNotVerified
+	}
+
+	// With MFA the password alone is not a successful login: the failures
+	// are only forgiven once the second factor is in too, so guessing codes
+	// cannot be reset by signing in with the password again.
+	if user.TOTPEnabledAt != nil {
+		return s.mfaChallenge(user)
+	}
+
+	s.resetLoginFailures(ctx, req.Email)
+
+	// A fresh login starts a new refresh-token family.
+	return s.issueTokens(ctx, user, uuid.New())
+}
+
+// Refresh exchanges a refresh token for a new access/refresh pair. The
+// presented token is revoked as part of the rotation; presenting it again
+// is treated as token theft and revokes every token in its family.
+func (s *Service) Refresh(ctx context.Context, req RefreshRequest) (*UserResponse, error) {
+	claims, err := s.jwt.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	tokenID, err := uuid.Parse(claims.RegisteredClaims.ID)
 	if err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	stored, err := s.repo.FindRefreshToken(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
 		return nil, err
 	}
 
-	return &UserResponse{
-		AccessToken: token,
-		Email:       user.Email,
-		Name:        user.Name,
-	}, nil
+	if stored.RevokedAt != nil {
+		return nil, s.revokeFamily(ctx, stored)
+	}
+
+	if stored.ExpiresAt.Before(time.Now()) {
+		return nil, errInvalidRefreshToken
+	}
+
+	rotated, err := s.repo.RevokeRefreshToken(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another request rotated this token between our read and the update.
+	if !rotated {
+		return nil, s.revokeFamily(ctx, stored)
+	}
+
+	user, err := s.repo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout revokes the access token the request was made with and, when given,
+// the refresh token family it belongs to.
+func (s *Service) Logout(ctx context.Context, principal *auth.Principal, req LogoutRequest) error {
+	ttl := time.Until(principal.ExpiresAt)
+	if err := s.revocation.RevokeToken(ctx, principal.TokenID, ttl); err != nil {
+		return err
+	}
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	refreshClaims, err := s.jwt.ParseRefreshToken(req.RefreshToken)
+	if err != nil || refreshClaims.ID != principal.ID.String() {
+		return errInvalidRefreshToken
+	}
+
+	tokenID, err := uuid.Parse(refreshClaims.RegisteredClaims.ID)
+	if err != nil {
+		return errInvalidRefreshToken
+	}
+
+	stored, err := s.repo.FindRefreshToken(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidRefreshToken
+		}
+		return err
+	}
+
+	return s.repo.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user so far.
+func (s *Service) LogoutAll(ctx context.Context, principal *auth.Principal) error {
+	if err := s.revocation.RevokeUser(ctx, principal.ID.String(), time.Now(), s.jwt.AccessTokenTTL()); err != nil {
+		return err
+	}
+
+	return s.repo.RevokeUserRefreshTokens(ctx, principal.ID)
 }
 
 func (s *Service) Save(ctx context.Context, user *entity.User) error {
 	return s.repo.Save(ctx, user)
 }
+
+func (s *Service) issueTokens(ctx context.Context, user *entity.User, familyID uuid.UUID) (*UserResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+
+	accessToken, refreshToken, err := s.jwt.GenerateToken(user.ID.String(), user.Email, roleNames(user))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.jwt.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, err := uuid.Parse(claims.RegisteredClaims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRefreshToken(ctx, &entity.RefreshToken{
+		ID:        tokenID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &UserResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Email:        user.Email,
+		Name:         user.Name,
+	}, nil
+}
+
+func (s *Service) revokeFamily(ctx context.Context, token *entity.RefreshToken) error {
+	log.Ctx(ctx).Warn().
+		Str("family_id", token.FamilyID.String()).
+		Str("user_id", token.UserID.String()).
+		Msg("refresh token reuse detected, revoking token family")
+
+	if err := s.repo.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+
+	return errInvalidRefreshToken
+}
+
+func roleNames(user *entity.User) []string {
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,237 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net-http-boilerplate/internal/config"
+	"net-http-boilerplate/internal/entity"
+	apperror "net-http-boilerplate/internal/pkg/app-error"
+	"net-http-boilerplate/internal/pkg/encrypt"
+	"net-http-boilerplate/internal/pkg/jwt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeRepo keeps users and refresh tokens in memory. It embeds Repo so a test
+// only implements what the flow under test calls; anything else panics.
+type fakeRepo struct {
+	Repo
+	users  map[uuid.UUID]*entity.User
+	tokens map[uuid.UUID]*entity.RefreshToken
+	// lostRace makes the next RevokeRefreshToken report that another request
+	// rotated the token first.
+	lostRace bool
+}
+
+func newFakeRepo(users ...*entity.User) *fakeRepo {
+	r := &fakeRepo{
+		users:  make(map[uuid.UUID]*entity.User),
+		tokens: make(map[uuid.UUID]*entity.RefreshToken),
+	}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeRepo) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *fakeRepo) FindRefreshToken(ctx context.Context, id uuid.UUID) (*entity.RefreshToken, error) {
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeRepo) RevokeRefreshToken(ctx context.Context, id uuid.UUID) (bool, error) {
+	token := r.tokens[id]
+	if r.lostRace || token.RevokedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+	return true, nil
+}
+
+func (r *fakeRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// live counts the tokens of a family that can still be refreshed.
+func (r *fakeRepo) live(familyID uuid.UUID) int {
+	n := 0
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestService(t *testing.T, repo Repo) *Service {
+	t.Helper()
+
+	if err := encrypt.Init("test-salt", "test-salt-iv", "aes-256-cbc"); err != nil {
+		t.Fatal(err)
+	}
+
+	j := jwt.NewJWT(config.JWT{Secret: "test-secret", AccessTTL: time.Hour, RefreshTTL: time.Hour})
+	return NewUserService(repo, j, nil, nil, nil, nil, &config.Config{})
+}
+
+func testUser() *entity.User {
+	return &entity.User{ID: uuid.New(), Name: "Test", Email: "test@example.com"}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	ctx := context.Background()
+	user := testUser()
+	repo := newFakeRepo(user)
+	service := newTestService(t, repo)
+
+	family := uuid.New()
+	first, err := service.issueTokens(ctx, user, family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := service.Refresh(ctx, RefreshRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Error("Refresh() did not issue a new token pair")
+	}
+
+	if n := repo.live(family); n != 1 {
+		t.Errorf("live tokens in the family = %d, want 1", n)
+	}
+
+	if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: second.RefreshToken}); err != nil {
+		t.Errorf("Refresh() with the rotated token = %v", err)
+	}
+}
+
+// TestRefreshReuseRevokesFamily replays a refresh token that was already
+// rotated, as a thief holding a copy would, and checks it takes the token the
+// legitimate client got in exchange down with it.
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	user := testUser()
+	repo := newFakeRepo(user)
+	service := newTestService(t, repo)
+
+	family := uuid.New()
+	stolen, err := service.issueTokens(ctx, user, family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := service.Refresh(ctx, RefreshRequest{RefreshToken: stolen.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another session of the same user must survive.
+	other, err := service.issueTokens(ctx, user, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: stolen.RefreshToken}); !errors.Is(err, apperror.ErrInvalidToken) {
+		t.Fatalf("Refresh() replaying a rotated token = %v, want %v", err, apperror.ErrInvalidToken)
+	}
+
+	if n := repo.live(family); n != 0 {
+		t.Errorf("live tokens in the family = %d, want 0", n)
+	}
+
+	if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: rotated.RefreshToken}); !errors.Is(err, apperror.ErrInvalidToken) {
+		t.Errorf("Refresh() with the token issued before the replay = %v, want %v", err, apperror.ErrInvalidToken)
+	}
+
+	if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: other.RefreshToken}); err != nil {
+		t.Errorf("Refresh() in another family = %v", err)
+	}
+}
+
+func TestRefreshConcurrentRotationRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	user := testUser()
+	repo := newFakeRepo(user)
+	service := newTestService(t, repo)
+
+	family := uuid.New()
+	first, err := service.issueTokens(ctx, user, family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.lostRace = true
+	if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: first.RefreshToken}); !errors.Is(err, apperror.ErrInvalidToken) {
+		t.Fatalf("Refresh() losing the rotation race = %v, want %v", err, apperror.ErrInvalidToken)
+	}
+
+	if n := repo.live(family); n != 0 {
+		t.Errorf("live tokens in the family = %d, want 0", n)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	ctx := context.Background()
+	user := testUser()
+	repo := newFakeRepo(user)
+	service := newTestService(t, repo)
+
+	issued, err := service.issueTokens(ctx, user, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown, err := newTestService(t, newFakeRepo(user)).issueTokens(ctx, user, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-jwt"},
+		{"access token", issued.AccessToken},
+		{"never stored", unknown.RefreshToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Refresh(ctx, RefreshRequest{RefreshToken: tt.token}); !errors.Is(err, apperror.ErrInvalidToken) {
+				t.Errorf("Refresh() = %v, want %v", err, apperror.ErrInvalidToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net-http-boilerplate/internal/entity"
+	"net-http-boilerplate/internal/pkg/mailer"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VerifyEmail redeems a verification token and marks its user as verified.
+func (s *Service) VerifyEmail(ctx context.Context, req VerifyEmailRequest) error {
+	token, err := s.repo.ConsumeUserToken(ctx, entity.TokenPurposeEmailVerification, s.tokens.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidVerificationToken
+		}
+		return err
+	}
+
+	return s.repo.MarkVerified(ctx, token.UserID)
+}
+
+// ResendVerification sends a new verification link. It never reports whether
+// the email belongs to an account or is already verified.
+func (s *Service) ResendVerification(ctx context.Context, req ResendVerificationRequest) error {
+	user, err := s.repo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+func (s *Service) sendVerification(ctx context.Context, user *entity.User) error {
+	if err := s.repo.InvalidateUserTokens(ctx, user.ID, entity.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	token, hash, err := s.tokens.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateUserToken(ctx, &entity.UserToken{
+		UserID:    user.ID,
+		Purpose:   entity.TokenPurposeEmailVerification,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.config.VerificationTokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address:\n\n%s\n\nThis link expires in %s.\n",
+			user.Name, tokenLink(s.config.VerificationURL, token), s.config.VerificationTokenTTL,
+		),
+	})
+}
+
+// tokenLink appends the token to the configured frontend URL. Without a URL
+// the bare token is sent so it can be posted to the API directly.
+func tokenLink(base string, token string) string {
+	if base == "" {
+		return token
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}